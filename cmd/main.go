@@ -5,22 +5,60 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/yu1ec/go-shorten/internal/auth"
+	"github.com/yu1ec/go-shorten/internal/codegen"
+	"github.com/yu1ec/go-shorten/internal/codepolicy"
+	"github.com/yu1ec/go-shorten/internal/config"
 	"github.com/yu1ec/go-shorten/internal/handler"
+	"github.com/yu1ec/go-shorten/internal/healthcheck"
+	"github.com/yu1ec/go-shorten/internal/reputation"
 	"github.com/yu1ec/go-shorten/internal/session"
 	"github.com/yu1ec/go-shorten/internal/storage"
 )
 
 func main() {
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("加载配置失败", slog.Any("error", err))
+		os.Exit(1)
+	}
+
 	// 初始化存储层
-	urlStorage, err := storage.NewURLStorage()
+	urlStorage, err := storage.NewURLStorage(cfg.CaseInsensitive)
 	if err != nil {
 		slog.Error("初始化URL存储失败", slog.Any("error", err))
 		os.Exit(1)
 	}
 
+	// 加载拦截列表，文件变化后自动重新加载，并定时扫描所有短链接
+	blocklist, err := reputation.NewBlocklist(cfg.BlocklistDir)
+	if err != nil {
+		slog.Error("加载拦截列表失败", slog.Any("error", err))
+		os.Exit(1)
+	}
+	blocklist.StartReloader(cfg.BlocklistReloadInterval)
+	reputation.StartScanner(urlStorage, blocklist, cfg.ReputationScanInterval)
+
+	// 定时检查目标地址是否仍可访问
+	if cfg.HealthCheckInterval > 0 {
+		client := &http.Client{Timeout: cfg.HealthCheckTimeout}
+		healthcheck.New(client, cfg.HealthCheckConcurrency, cfg.HealthCheckHostInterval).Start(urlStorage, cfg.HealthCheckInterval)
+	}
+
+	// 短代码生成策略
+	codeGen, err := codegen.New(cfg.CodeGenerator, cfg.CodeAlphabet, filepath.Join(storage.DataDir, "code_counter"), cfg.CodeSalt)
+	if err != nil {
+		slog.Error("初始化短代码生成器失败", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// 自定义短代码规则，保留字在注册路由时自动添加
+	codePolicy := codepolicy.New(cfg.CodeCharset, cfg.CodeMinLength, cfg.CodeMaxLength, cfg.BlockedWords)
+
 	// 初始化用户管理器
 	userManager, err := auth.NewUserManager()
 	if err != nil {
@@ -34,38 +72,38 @@ func main() {
 
 	// 创建HTTP处理器
 	mux := http.NewServeMux()
+	handle := func(pattern string, h http.Handler) {
+		mux.Handle(pattern, h)
+		codePolicy.ReserveRoute(pattern)
+	}
 
-	// 创建API处理器
-	apiHandler := handler.NewAPIHTTPHandler(urlStorage, userManager)
-	mux.Handle("/api/shorten", apiHandler)
+	// 创建API处理器和API文档
+	apiHandler := handler.NewAPIHTTPHandler(urlStorage, userManager, cfg, blocklist, codeGen, codePolicy)
+	apiDocsHandler := handler.NewAPIDocsHTTPHandler()
+	handler.RegisterAPIRoutes(handle, apiHandler, apiDocsHandler)
 
 	// 创建管理界面处理器
-	adminHandler := handler.NewAdminHTTPHandler(urlStorage, userManager, sessionMgr)
+	adminHandler := handler.NewAdminHTTPHandler(urlStorage, userManager, sessionMgr, cfg, blocklist, codeGen, codePolicy)
 
 	// 登录相关路由
-	mux.Handle("/login", adminHandler)
-	mux.Handle("/logout", adminHandler)
+	handle("/login", adminHandler)
+	handle("/logout", adminHandler)
 
 	// 管理面板路由
-	mux.Handle("/admin", adminHandler)
-	mux.Handle("/admin/", adminHandler)
+	handle("/admin", adminHandler)
+	handle("/admin/", adminHandler)
 
 	// 重定向处理器（必须放在最后注册，因为它处理所有根路径下的请求）
-	redirectHandler := handler.NewRedirectHTTPHandler(urlStorage)
-	mux.Handle("/", redirectHandler)
+	redirectHandler := handler.NewRedirectHTTPHandler(urlStorage, cfg)
+	handle("/", redirectHandler)
 
 	// 启动服务器
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "5768"
-	}
-
 	server := &http.Server{
-		Addr:    ":" + port,
+		Addr:    ":" + cfg.Port,
 		Handler: mux,
 	}
 
-	log.Println("Starting server on :" + port)
+	log.Println("Starting server on :" + cfg.Port)
 	if err := server.ListenAndServe(); err != nil {
 		slog.Error("启动服务器失败", slog.Any("error", err))
 		os.Exit(1)
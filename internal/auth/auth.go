@@ -4,6 +4,7 @@ import (
 	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -16,6 +17,42 @@ const (
 	UserFile = "users.json"
 )
 
+// 用户管理的错误类型，使用errors.Is判断，面向用户的提示信息由处理器生成
+var (
+	// ErrNotFound 用户不存在
+	ErrNotFound = errors.New("user not found")
+
+	// ErrConflict 用户已存在
+	ErrConflict = errors.New("user already exists")
+
+	// ErrInvalid 用户名或密码为空
+	ErrInvalid = errors.New("invalid user")
+)
+
+// 与错误相关的字段
+const (
+	FieldUsername = "username"
+	FieldPassword = "password"
+)
+
+// Error 带上下文的用户管理错误，Kind为ErrNotFound、ErrConflict或ErrInvalid，
+// Field为出错的字段，Value为相关的用户名
+type Error struct {
+	Kind  error
+	Field string
+	Value string
+}
+
+// Error 实现error接口
+func (e *Error) Error() string {
+	return fmt.Sprintf("auth: %s %q: %v", e.Field, e.Value, e.Kind)
+}
+
+// Unwrap 返回错误类型，用于errors.Is
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
 // User 表示系统用户
 type User struct {
 	Username     string `json:"username"`
@@ -96,8 +133,11 @@ func (m *UserManager) loadUsers() error {
 
 // CreateUser 创建新用户
 func (m *UserManager) CreateUser(username, password string, isAdmin bool) error {
-	if username == "" || password == "" {
-		return errors.New("用户名和密码不能为空")
+	if username == "" {
+		return &Error{Kind: ErrInvalid, Field: FieldUsername}
+	}
+	if password == "" {
+		return &Error{Kind: ErrInvalid, Field: FieldPassword, Value: username}
 	}
 
 	m.mutex.Lock()
@@ -105,7 +145,7 @@ func (m *UserManager) CreateUser(username, password string, isAdmin bool) error
 
 	// 检查用户是否已存在
 	if _, exists := m.users[username]; exists {
-		return errors.New("用户已存在")
+		return &Error{Kind: ErrConflict, Field: FieldUsername, Value: username}
 	}
 
 	// 生成密码哈希
@@ -149,7 +189,7 @@ func (m *UserManager) Authenticate(username, password string) (bool, error) {
 
 	user, exists := m.users[username]
 	if !exists {
-		return false, errors.New("用户不存在")
+		return false, &Error{Kind: ErrNotFound, Field: FieldUsername, Value: username}
 	}
 
 	// 验证密码
@@ -164,7 +204,7 @@ func (m *UserManager) GetUser(username string) (User, error) {
 
 	user, exists := m.users[username]
 	if !exists {
-		return User{}, errors.New("用户不存在")
+		return User{}, &Error{Kind: ErrNotFound, Field: FieldUsername, Value: username}
 	}
 
 	return user, nil
@@ -173,7 +213,7 @@ func (m *UserManager) GetUser(username string) (User, error) {
 // UpdatePassword 更新用户密码
 func (m *UserManager) UpdatePassword(username, newPassword string) error {
 	if newPassword == "" {
-		return errors.New("密码不能为空")
+		return &Error{Kind: ErrInvalid, Field: FieldPassword, Value: username}
 	}
 
 	m.mutex.Lock()
@@ -181,7 +221,7 @@ func (m *UserManager) UpdatePassword(username, newPassword string) error {
 
 	user, exists := m.users[username]
 	if !exists {
-		return errors.New("用户不存在")
+		return &Error{Kind: ErrNotFound, Field: FieldUsername, Value: username}
 	}
 
 	// 生成新的密码哈希
@@ -203,7 +243,7 @@ func (m *UserManager) DeleteUser(username string) error {
 	defer m.mutex.Unlock()
 
 	if _, exists := m.users[username]; !exists {
-		return errors.New("用户不存在")
+		return &Error{Kind: ErrNotFound, Field: FieldUsername, Value: username}
 	}
 
 	delete(m.users, username)
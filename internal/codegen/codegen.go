@@ -0,0 +1,86 @@
+// Package codegen 提供多种短代码生成策略
+package codegen
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 常用字符集
+const (
+	AlphabetBase62 = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	// AlphabetUnambiguous 去掉容易混淆的 0/O/o、1/l/I 后的字符集，适合印刷后手动输入
+	AlphabetUnambiguous = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// 每个长度尝试的次数，以及冲突时长度最多增加的位数
+const (
+	attemptsPerLength = 3
+	maxLengthGrowth   = 4
+)
+
+// ErrExhausted 多次尝试后仍无法生成未被使用的短代码
+var ErrExhausted = errors.New("无法生成未被使用的短代码")
+
+// Generator 短代码生成器，length为期望的最小长度
+type Generator interface {
+	Generate(length int) (string, error)
+}
+
+// Unique 生成未被使用的短代码，冲突时重试，多次冲突后增加长度
+func Unique(gen Generator, length int, taken func(code string) bool) (string, error) {
+	for l := length; l <= length+maxLengthGrowth; l++ {
+		for i := 0; i < attemptsPerLength; i++ {
+			code, err := gen.Generate(l)
+			if err != nil {
+				return "", err
+			}
+			if !taken(code) {
+				return code, nil
+			}
+		}
+	}
+	return "", ErrExhausted
+}
+
+// New 按名称创建生成器：random、counter、hashids、pronounceable
+// counterPath为计数器的保存文件，salt为hashids的混淆盐值
+func New(name, alphabet, counterPath, salt string) (Generator, error) {
+	if len(alphabet) < 2 {
+		return nil, errors.New("短代码字符集至少需要2个字符")
+	}
+
+	switch name {
+	case "", "random":
+		return &Random{Alphabet: alphabet}, nil
+	case "counter":
+		counter, err := NewCounter(counterPath)
+		if err != nil {
+			return nil, err
+		}
+		return &Sequential{Counter: counter, Alphabet: alphabet}, nil
+	case "hashids":
+		counter, err := NewCounter(counterPath)
+		if err != nil {
+			return nil, err
+		}
+		return &Hashids{Counter: counter, Alphabet: alphabet, Salt: salt}, nil
+	case "pronounceable":
+		return &Pronounceable{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的短代码生成策略: %s", name)
+	}
+}
+
+// ResolveAlphabet 将预设名称转换为字符集，其他值原样作为字符集
+func ResolveAlphabet(value string) string {
+	switch value {
+	case "", "base62":
+		return AlphabetBase62
+	case "unambiguous":
+		return AlphabetUnambiguous
+	default:
+		return value
+	}
+}
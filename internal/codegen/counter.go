@@ -0,0 +1,86 @@
+package codegen
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Counter 持久化的自增计数器，每次取值后立即写入文件，重启后继续递增
+type Counter struct {
+	mutex sync.Mutex
+	path  string
+	value uint64
+}
+
+// NewCounter 从文件加载计数器，文件不存在时从0开始
+func NewCounter(path string) (*Counter, error) {
+	c := &Counter{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("读取短代码计数器失败: %w", err)
+	}
+
+	c.value, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析短代码计数器失败: %w", err)
+	}
+	return c, nil
+}
+
+// Next 返回下一个值
+func (c *Counter) Next() (uint64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.value++
+	if err := os.WriteFile(c.path, []byte(strconv.FormatUint(c.value, 10)), 0644); err != nil {
+		c.value--
+		return 0, fmt.Errorf("保存短代码计数器失败: %w", err)
+	}
+	return c.value, nil
+}
+
+// Sequential 将自增计数器编码为短代码，不足长度时在前面补字符集的第一个字符
+type Sequential struct {
+	Counter  *Counter
+	Alphabet string
+}
+
+// Generate 实现Generator接口
+func (g *Sequential) Generate(length int) (string, error) {
+	n, err := g.Counter.Next()
+	if err != nil {
+		return "", err
+	}
+
+	code := encode(n, g.Alphabet)
+	if len(code) < length {
+		code = strings.Repeat(g.Alphabet[:1], length-len(code)) + code
+	}
+	return code, nil
+}
+
+// encode 将数字编码为指定字符集表示的字符串
+func encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	var b []byte
+	for {
+		b = append(b, alphabet[n%base])
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,58 @@
+package codegen
+
+// Hashids 参考 Hashids 算法将自增计数器编码为看似随机的短代码，
+// 不同的盐值得到不同的编码，无法从短代码直接推算出创建顺序
+type Hashids struct {
+	Counter  *Counter
+	Alphabet string
+	Salt     string
+}
+
+// Generate 实现Generator接口
+func (g *Hashids) Generate(length int) (string, error) {
+	n, err := g.Counter.Next()
+	if err != nil {
+		return "", err
+	}
+
+	alphabet := shuffle([]byte(g.Alphabet), []byte(g.Salt))
+
+	// 首字符由数字决定，并参与后续字符集的打乱
+	lottery := alphabet[n%uint64(len(alphabet))]
+	buffer := append([]byte{lottery}, g.Salt...)
+	buffer = append(buffer, alphabet...)
+	alphabet = shuffle(alphabet, buffer[:len(alphabet)])
+
+	code := append([]byte{lottery}, encode(n, string(alphabet))...)
+
+	// 长度不足时在两侧补充打乱后的字符
+	for len(code) < length {
+		alphabet = shuffle(alphabet, alphabet)
+		half := len(alphabet) / 2
+		padded := append(append(append([]byte{}, alphabet[half:]...), code...), alphabet[:half]...)
+		if excess := len(padded) - length; excess > 0 {
+			start := excess / 2
+			padded = padded[start : start+length]
+		}
+		code = padded
+	}
+
+	return string(code), nil
+}
+
+// shuffle 使用盐值对字符集进行确定性打乱，相同的输入总是得到相同的结果
+func shuffle(alphabet, salt []byte) []byte {
+	result := append([]byte{}, alphabet...)
+	if len(salt) == 0 {
+		return result
+	}
+
+	for i, v, p := len(result)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		p += int(salt[v])
+		j := (int(salt[v]) + v + p) % i
+		result[i], result[j] = result[j], result[i]
+		v++
+	}
+	return result
+}
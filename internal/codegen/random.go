@@ -0,0 +1,54 @@
+package codegen
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// Random 从字符集中随机选取字符
+type Random struct {
+	Alphabet string
+}
+
+// Generate 实现Generator接口
+func (g *Random) Generate(length int) (string, error) {
+	return randomString(g.Alphabet, length)
+}
+
+// randomString 使用加密安全的随机数生成指定长度的字符串
+func randomString(alphabet string, length int) (string, error) {
+	b := make([]byte, length)
+	for i := range b {
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[num.Int64()]
+	}
+	return string(b), nil
+}
+
+// Pronounceable 由辅音和元音交替组成的易读短代码，如 bakuto
+type Pronounceable struct{}
+
+const (
+	consonants = "bdfgkmnprstvz"
+	vowels     = "aeiou"
+)
+
+// Generate 实现Generator接口
+func (g *Pronounceable) Generate(length int) (string, error) {
+	b := make([]byte, 0, length)
+	for len(b) < length {
+		set := consonants
+		if len(b)%2 == 1 {
+			set = vowels
+		}
+		c, err := randomString(set, 1)
+		if err != nil {
+			return "", err
+		}
+		b = append(b, c[0])
+	}
+	return string(b), nil
+}
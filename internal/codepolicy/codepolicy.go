@@ -0,0 +1,97 @@
+// Package codepolicy 校验自定义短代码：字符集、长度、保留字和禁用词
+package codepolicy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultCharset 默认允许的短代码字符
+const DefaultCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+// builtinReserved 不在路由中注册但不应被用作短代码的路径
+var builtinReserved = []string{"favicon.ico", "robots.txt", "static", "assets", "health"}
+
+// Policy 短代码校验规则，保留字和禁用词均不区分大小写
+type Policy struct {
+	charset   string
+	minLength int
+	maxLength int
+	reserved  map[string]bool
+	blocked   []string
+}
+
+// New 创建校验规则，blocked为禁止出现在短代码中的词（如不雅词汇、品牌名）
+func New(charset string, minLength, maxLength int, blocked []string) *Policy {
+	p := &Policy{
+		charset:   charset,
+		minLength: minLength,
+		maxLength: maxLength,
+		reserved:  make(map[string]bool),
+	}
+	for _, word := range blocked {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			p.blocked = append(p.blocked, word)
+		}
+	}
+	p.Reserve(builtinReserved...)
+	return p
+}
+
+// Reserve 添加保留字
+func (p *Policy) Reserve(words ...string) {
+	for _, word := range words {
+		p.reserved[strings.ToLower(word)] = true
+	}
+}
+
+// ReserveRoute 将路由的第一段路径加入保留字，如 /api/shorten 保留 api
+func (p *Policy) ReserveRoute(pattern string) {
+	// 兼容带方法和域名的路由写法，如 "POST example.com/api/x"
+	if _, path, found := strings.Cut(pattern, " "); found {
+		pattern = path
+	}
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+
+	segment, _, _ := strings.Cut(strings.TrimPrefix(pattern, "/"), "/")
+	if segment != "" && !strings.HasPrefix(segment, "{") {
+		p.Reserve(segment)
+	}
+}
+
+// Validate 校验短代码，返回面向用户的错误信息
+func (p *Policy) Validate(code string) error {
+	length := len([]rune(code))
+	if length < p.minLength || length > p.maxLength {
+		return fmt.Errorf("短代码长度必须在%d到%d个字符之间", p.minLength, p.maxLength)
+	}
+
+	for _, c := range code {
+		if !strings.ContainsRune(p.charset, c) {
+			if c == '/' {
+				return errors.New("短代码不能包含 /")
+			}
+			return fmt.Errorf("短代码包含不允许的字符: %q", c)
+		}
+	}
+
+	lower := strings.ToLower(code)
+	if p.reserved[lower] {
+		return fmt.Errorf("短代码 %s 是系统保留字", code)
+	}
+	for _, word := range p.blocked {
+		if strings.Contains(lower, word) {
+			return errors.New("短代码包含禁用词")
+		}
+	}
+
+	return nil
+}
+
+// Allowed 判断短代码是否符合规则，用于过滤自动生成的短代码
+func (p *Policy) Allowed(code string) bool {
+	return p.Validate(code) == nil
+}
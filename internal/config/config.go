@@ -0,0 +1,399 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yu1ec/go-shorten/internal/codegen"
+	"github.com/yu1ec/go-shorten/internal/codepolicy"
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// Config 应用配置，全部从环境变量读取
+type Config struct {
+	// 服务监听端口
+	Port string
+
+	// 密码保护链接：解锁Cookie的签名密钥及有效期
+	UnlockSecret []byte
+	UnlockTTL    time.Duration
+
+	// 密码保护链接：错误尝试限制，窗口期内超过次数后拒绝继续尝试
+	UnlockMaxAttempts   int
+	UnlockAttemptWindow time.Duration
+
+	// 跳转提示页的倒计时秒数
+	InterstitialSeconds int
+
+	// 多域名配置，未列出的域名使用默认命名空间
+	Domains []Domain
+
+	// 根路径跳转的首页地址，为空时展示链接不存在页面
+	HomeURL string
+
+	// 未知短码的兜底跳转地址，为空时展示链接不存在页面
+	NotFoundURL string
+
+	// 链接不存在页面推荐的相似短码数量，0表示不推荐
+	NotFoundSuggestions int
+
+	// 目标地址校验：允许的协议、最大长度，以及禁止使用的域名和地址正则
+	AllowedSchemes []string
+	MaxURLLength   int
+	DenyHosts      []string
+	DenyPatterns   []*regexp.Regexp
+
+	// 拦截列表目录及重新加载检查间隔，以及定时扫描所有短链接的间隔
+	BlocklistDir            string
+	BlocklistReloadInterval time.Duration
+	ReputationScanInterval  time.Duration
+
+	// 短代码生成：策略、长度、字符集，以及hashids策略的混淆盐值
+	CodeGenerator string
+	CodeLength    int
+	CodeAlphabet  string
+	CodeSalt      string
+
+	// 自定义短代码规则：允许的字符、长度范围，以及禁止出现在短代码中的词
+	CodeCharset   string
+	CodeMinLength int
+	CodeMaxLength int
+	BlockedWords  []string
+
+	// 短代码和别名是否不区分大小写
+	CaseInsensitive bool
+
+	// 目标地址健康检查：检查间隔（0表示关闭）、并发数、同一域名的请求间隔和请求超时
+	HealthCheckInterval     time.Duration
+	HealthCheckConcurrency  int
+	HealthCheckHostInterval time.Duration
+	HealthCheckTimeout      time.Duration
+
+	// 创建接口Idempotency-Key对应响应的保存时长
+	IdempotencyTTL time.Duration
+}
+
+// Domain 单个域名的配置
+type Domain struct {
+	Host         string `json:"host"`
+	FallbackURL  string `json:"fallback_url,omitempty"`   // 根路径和未知短代码跳转到此地址
+	NotFoundPage string `json:"not_found_page,omitempty"` // 未设置FallbackURL时，未知短代码返回此HTML文件的内容
+
+	NotFoundHTML []byte `json:"-"`
+}
+
+// Domain 查找域名配置，未配置时返回nil
+func (c *Config) Domain(host string) *Domain {
+	host = storage.NormalizeDomain(host)
+	for i := range c.Domains {
+		if c.Domains[i].Host == host {
+			return &c.Domains[i]
+		}
+	}
+	return nil
+}
+
+// DomainHosts 返回所有已配置的域名
+func (c *Config) DomainHosts() []string {
+	hosts := make([]string, 0, len(c.Domains))
+	for _, d := range c.Domains {
+		hosts = append(hosts, d.Host)
+	}
+	return hosts
+}
+
+// Load 从环境变量加载配置
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:        getEnv("PORT", "5768"),
+		HomeURL:     getEnv("SHORTEN_HOME_URL", ""),
+		NotFoundURL: getEnv("SHORTEN_NOT_FOUND_URL", ""),
+
+		CodeGenerator: getEnv("SHORTEN_CODE_GENERATOR", "random"),
+		CodeAlphabet:  codegen.ResolveAlphabet(getEnv("SHORTEN_CODE_ALPHABET", "base62")),
+		CodeSalt:      getEnv("SHORTEN_CODE_SALT", "go-shorten"),
+		CodeCharset:   getEnv("SHORTEN_CODE_CHARSET", codepolicy.DefaultCharset),
+	}
+
+	var err error
+	if cfg.UnlockTTL, err = getEnvDuration("SHORTEN_UNLOCK_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.UnlockTTL <= 0 {
+		return nil, errors.New("SHORTEN_UNLOCK_TTL必须大于0")
+	}
+	if cfg.UnlockMaxAttempts, err = getEnvInt("SHORTEN_UNLOCK_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.UnlockMaxAttempts <= 0 {
+		return nil, errors.New("SHORTEN_UNLOCK_MAX_ATTEMPTS必须大于0")
+	}
+	if cfg.UnlockAttemptWindow, err = getEnvDuration("SHORTEN_UNLOCK_ATTEMPT_WINDOW", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.UnlockAttemptWindow <= 0 {
+		return nil, errors.New("SHORTEN_UNLOCK_ATTEMPT_WINDOW必须大于0")
+	}
+	if cfg.InterstitialSeconds, err = getEnvInt("SHORTEN_INTERSTITIAL_SECONDS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.NotFoundSuggestions, err = getEnvInt("SHORTEN_NOT_FOUND_SUGGESTIONS", 5); err != nil {
+		return nil, err
+	}
+
+	cfg.AllowedSchemes = getEnvList("SHORTEN_ALLOWED_SCHEMES", []string{"http", "https"})
+	if cfg.MaxURLLength, err = getEnvInt("SHORTEN_MAX_URL_LENGTH", 2048); err != nil {
+		return nil, err
+	}
+	cfg.BlocklistDir = getEnv("SHORTEN_BLOCKLIST_DIR", filepath.Join(storage.DataDir, "blocklists"))
+	if cfg.BlocklistReloadInterval, err = getEnvDuration("SHORTEN_BLOCKLIST_RELOAD_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.BlocklistReloadInterval <= 0 {
+		return nil, errors.New("SHORTEN_BLOCKLIST_RELOAD_INTERVAL必须大于0")
+	}
+	if cfg.ReputationScanInterval, err = getEnvDuration("SHORTEN_REPUTATION_SCAN_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.ReputationScanInterval <= 0 {
+		return nil, errors.New("SHORTEN_REPUTATION_SCAN_INTERVAL必须大于0")
+	}
+
+	if cfg.CodeLength, err = getEnvInt("SHORTEN_CODE_LENGTH", 6); err != nil {
+		return nil, err
+	}
+	if cfg.CodeLength < 1 {
+		return nil, errors.New("环境变量SHORTEN_CODE_LENGTH必须大于0")
+	}
+
+	if cfg.CodeMinLength, err = getEnvInt("SHORTEN_CODE_MIN_LENGTH", 1); err != nil {
+		return nil, err
+	}
+	if cfg.CodeMaxLength, err = getEnvInt("SHORTEN_CODE_MAX_LENGTH", 64); err != nil {
+		return nil, err
+	}
+	if cfg.CodeMinLength < 1 || cfg.CodeMaxLength < cfg.CodeMinLength {
+		return nil, errors.New("短代码长度范围配置错误")
+	}
+	// 自动生成的短代码同样要通过字符集校验，字母表中不能有字符集不允许的字符
+	for _, c := range cfg.CodeAlphabet {
+		if !strings.ContainsRune(cfg.CodeCharset, c) {
+			return nil, fmt.Errorf("SHORTEN_CODE_ALPHABET中的字符%q不在SHORTEN_CODE_CHARSET中", c)
+		}
+	}
+	if cfg.BlockedWords, err = loadWordList(getEnv("SHORTEN_BLOCKED_WORDS_FILE", filepath.Join(storage.DataDir, "blocked_words.txt"))); err != nil {
+		return nil, err
+	}
+
+	if cfg.CaseInsensitive, err = getEnvBool("SHORTEN_CASE_INSENSITIVE", false); err != nil {
+		return nil, err
+	}
+
+	if cfg.HealthCheckInterval, err = getEnvDuration("SHORTEN_HEALTH_CHECK_INTERVAL", 6*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.HealthCheckConcurrency, err = getEnvInt("SHORTEN_HEALTH_CHECK_CONCURRENCY", 4); err != nil {
+		return nil, err
+	}
+	if cfg.HealthCheckHostInterval, err = getEnvDuration("SHORTEN_HEALTH_CHECK_HOST_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HealthCheckHostInterval <= 0 {
+		return nil, errors.New("SHORTEN_HEALTH_CHECK_HOST_INTERVAL必须大于0")
+	}
+	if cfg.HealthCheckTimeout, err = getEnvDuration("SHORTEN_HEALTH_CHECK_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		return nil, errors.New("SHORTEN_HEALTH_CHECK_TIMEOUT必须大于0")
+	}
+
+	if cfg.IdempotencyTTL, err = getEnvDuration("SHORTEN_IDEMPOTENCY_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		return nil, errors.New("SHORTEN_IDEMPOTENCY_TTL必须大于0")
+	}
+
+	if cfg.DenyHosts, cfg.DenyPatterns, err = loadURLDenylist(getEnv("SHORTEN_URL_DENYLIST_FILE", filepath.Join(storage.DataDir, "url_denylist.txt"))); err != nil {
+		return nil, err
+	}
+
+	if cfg.Domains, err = loadDomains(getEnv("SHORTEN_DOMAINS_FILE", filepath.Join(storage.DataDir, "domains.json"))); err != nil {
+		return nil, err
+	}
+
+	// 未配置签名密钥时随机生成，重启后已解锁的链接需要重新输入密码
+	if secret := os.Getenv("SHORTEN_UNLOCK_SECRET"); secret != "" {
+		cfg.UnlockSecret = []byte(secret)
+	} else {
+		cfg.UnlockSecret = make([]byte, 32)
+		if _, err := rand.Read(cfg.UnlockSecret); err != nil {
+			return nil, fmt.Errorf("生成解锁签名密钥失败: %w", err)
+		}
+		slog.Warn("未设置SHORTEN_UNLOCK_SECRET，使用随机密钥，重启后解锁状态将失效")
+	}
+
+	return cfg, nil
+}
+
+// loadDomains 从JSON文件加载多域名配置，文件不存在时返回空配置
+func loadDomains(path string) ([]Domain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取域名配置失败: %w", err)
+	}
+
+	var domains []Domain
+	if err := json.Unmarshal(data, &domains); err != nil {
+		return nil, fmt.Errorf("解析域名配置失败: %w", err)
+	}
+
+	for i := range domains {
+		domains[i].Host = storage.NormalizeDomain(domains[i].Host)
+		if domains[i].Host == "" {
+			return nil, errors.New("域名配置中的host不能为空")
+		}
+
+		if domains[i].NotFoundPage != "" {
+			html, err := os.ReadFile(domains[i].NotFoundPage)
+			if err != nil {
+				return nil, fmt.Errorf("读取域名%s的404页面失败: %w", domains[i].Host, err)
+			}
+			domains[i].NotFoundHTML = html
+		}
+	}
+
+	return domains, nil
+}
+
+// loadURLDenylist 加载目标地址禁止列表，文件不存在时返回空列表
+// 每行一条规则：普通行为域名（同时匹配子域名），以 / 包围的行为匹配完整地址的正则，# 开头为注释
+func loadURLDenylist(path string) ([]string, []*regexp.Regexp, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("读取地址禁止列表失败: %w", err)
+	}
+
+	var hosts []string
+	var patterns []*regexp.Regexp
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			re, err := regexp.Compile(line[1 : len(line)-1])
+			if err != nil {
+				return nil, nil, fmt.Errorf("地址禁止列表第%d行正则错误: %w", i+1, err)
+			}
+			patterns = append(patterns, re)
+			continue
+		}
+		hosts = append(hosts, strings.ToLower(strings.TrimSuffix(line, ".")))
+	}
+
+	return hosts, patterns, nil
+}
+
+// loadWordList 加载每行一个词的列表文件，忽略空行和 # 开头的注释，文件不存在时返回空列表
+func loadWordList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取禁用词列表失败: %w", err)
+	}
+
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, nil
+}
+
+// getEnv 读取字符串类型的环境变量
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvList 读取逗号分隔的列表类型环境变量
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvInt 读取整数类型的环境变量
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("环境变量%s格式错误: %w", key, err)
+	}
+	return n, nil
+}
+
+// getEnvBool 读取布尔类型的环境变量，格式如 "true"、"1"
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("环境变量%s格式错误: %w", key, err)
+	}
+	return b, nil
+}
+
+// getEnvDuration 读取时长类型的环境变量，格式如 "30m"、"24h"
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("环境变量%s格式错误: %w", key, err)
+	}
+	return d, nil
+}
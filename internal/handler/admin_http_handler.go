@@ -6,11 +6,20 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/yu1ec/go-shorten/internal/auth"
+	"github.com/yu1ec/go-shorten/internal/codegen"
+	"github.com/yu1ec/go-shorten/internal/codepolicy"
+	"github.com/yu1ec/go-shorten/internal/config"
+	"github.com/yu1ec/go-shorten/internal/reputation"
 	"github.com/yu1ec/go-shorten/internal/session"
 	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/urlcheck"
+	"github.com/yu1ec/go-shorten/internal/useragent"
 	html_templates "github.com/yu1ec/go-shorten/templates"
 )
 
@@ -19,12 +28,17 @@ type AdminHTTPHandler struct {
 	urlStorage   *storage.URLStorage
 	userManager  *auth.UserManager
 	sessionMgr   *session.Manager
+	cfg          *config.Config
+	urlValidator *urlcheck.Validator
+	checker      reputation.Checker
+	codeGen      codegen.Generator
+	codePolicy   *codepolicy.Policy
 	templates    map[string]*template.Template
 	baseTemplate *template.Template
 }
 
 // NewAdminHTTPHandler 创建管理界面处理器
-func NewAdminHTTPHandler(urlStorage *storage.URLStorage, userManager *auth.UserManager, sessionMgr *session.Manager) *AdminHTTPHandler {
+func NewAdminHTTPHandler(urlStorage *storage.URLStorage, userManager *auth.UserManager, sessionMgr *session.Manager, cfg *config.Config, checker reputation.Checker, codeGen codegen.Generator, codePolicy *codepolicy.Policy) *AdminHTTPHandler {
 	// 加载模板
 	templates := make(map[string]*template.Template)
 
@@ -46,7 +60,7 @@ func NewAdminHTTPHandler(urlStorage *storage.URLStorage, userManager *auth.UserM
 		}
 
 		// 创建模板并解析
-		tmpl := template.New(file)
+		tmpl := template.New(file).Funcs(templateFuncs)
 		tmpl, err = tmpl.Parse(string(layoutContent))
 		if err != nil {
 			log.Fatalf("解析layout.html失败: %v", err)
@@ -61,17 +75,7 @@ func NewAdminHTTPHandler(urlStorage *storage.URLStorage, userManager *auth.UserM
 	}
 
 	// 独立模板（不需要layout）
-	standaloneFiles := []string{
-		"login.html", "error.html",
-	}
-
-	for _, file := range standaloneFiles {
-		content, err := html_templates.AdminUIFS.ReadFile(file)
-		if err != nil {
-			log.Fatalf("读取%s失败: %v", file, err)
-		}
-
-		tmpl := template.Must(template.New(file).Parse(string(content)))
+	for file, tmpl := range parseStandaloneTemplates("login.html", "error.html") {
 		templates[file] = tmpl
 	}
 
@@ -79,6 +83,11 @@ func NewAdminHTTPHandler(urlStorage *storage.URLStorage, userManager *auth.UserM
 		urlStorage:   urlStorage,
 		userManager:  userManager,
 		sessionMgr:   sessionMgr,
+		cfg:          cfg,
+		urlValidator: newURLValidator(cfg),
+		checker:      checker,
+		codeGen:      codeGen,
+		codePolicy:   codePolicy,
 		templates:    templates,
 		baseTemplate: nil, // 不再需要baseTemplate
 	}
@@ -113,6 +122,8 @@ func (h *AdminHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.withAuth(h.handleUpdateURL)(w, r)
 	case regexp.MustCompile(`^/admin/urls/([^/]+)/delete$`).MatchString(r.URL.Path) && r.Method == http.MethodPost:
 		h.withAuth(h.handleDeleteURL)(w, r)
+	case regexp.MustCompile(`^/admin/urls/([^/]+)/rename$`).MatchString(r.URL.Path) && r.Method == http.MethodPost:
+		h.withAuth(h.handleRenameURL)(w, r)
 
 	default:
 		// 404页面
@@ -250,11 +261,20 @@ func (h *AdminHTTPHandler) handleDashboard(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	brokenCount := 0
+	for _, u := range urls {
+		if u.IsBroken() {
+			brokenCount++
+		}
+	}
+
 	h.renderTemplate(w, "dashboard.html", map[string]interface{}{
-		"title":    "管理面板",
-		"username": username,
-		"urls":     urls,
-		"urlCount": len(urls),
+		"title":       "管理面板",
+		"username":    username,
+		"urls":        urls,
+		"urlCount":    len(urls),
+		"brokenCount": brokenCount,
+		"chainDepths": newChainResolver(h.urlStorage, h.cfg, r).chainDepths(urls),
 	})
 }
 
@@ -269,21 +289,16 @@ func (h *AdminHTTPHandler) handleListURLs(w http.ResponseWriter, r *http.Request
 	}
 
 	h.renderTemplate(w, "urls.html", map[string]interface{}{
-		"title":    "短链接管理",
-		"username": username,
-		"urls":     urls,
+		"title":       "短链接管理",
+		"username":    username,
+		"urls":        urls,
+		"chainDepths": newChainResolver(h.urlStorage, h.cfg, r).chainDepths(urls),
 	})
 }
 
 // 处理新建URL表单
 func (h *AdminHTTPHandler) handleNewURLForm(w http.ResponseWriter, r *http.Request) {
-	username := getContextValue(r, "username").(string)
-
-	h.renderTemplate(w, "url_form.html", map[string]interface{}{
-		"title":    "创建短链接",
-		"username": username,
-		"isNew":    true,
-	})
+	h.renderURLForm(w, r, storage.URLRecord{}, true, "", nil)
 }
 
 // 处理创建URL
@@ -293,53 +308,55 @@ func (h *AdminHTTPHandler) handleCreateURL(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	username := getContextValue(r, "username").(string)
+	record, err := parseURLForm(r)
+	record.ShortCode = r.FormValue("short_code")
+	record.Domain = storage.NormalizeDomain(r.FormValue("domain"))
+	if err != nil {
+		h.renderURLForm(w, r, record, true, err.Error(), nil)
+		return
+	}
+	if err := applyPasswordForm(r, &record); err != nil {
+		h.renderErrorPage(w, "错误", "设置访问密码失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	targetURL := r.FormValue("target_url")
-	shortCode := r.FormValue("short_code")
-	remark := r.FormValue("remark")
-
-	// 验证目标URL
-	if targetURL == "" {
-		h.renderTemplate(w, "url_form.html", map[string]interface{}{
-			"title":     "创建短链接",
-			"error":     "目标URL不能为空",
-			"username":  username,
-			"targetURL": targetURL,
-			"shortCode": shortCode,
-			"remark":    remark,
-			"isNew":     true,
-		})
+	// 验证表单
+	if msg := validateURLForm(record); msg != "" {
+		h.renderURLForm(w, r, record, true, msg, nil)
+		return
+	}
+	if errs := h.checkRecordURLs(r, &record); len(errs) > 0 {
+		h.renderURLForm(w, r, record, true, "请修正以下字段中的地址", errs)
+		return
+	}
+	if record.Domain != "" && h.cfg.Domain(record.Domain) == nil {
+		h.renderURLForm(w, r, record, true, "域名未配置", nil)
+		return
+	}
+	if record.ShortCode != "" {
+		if err := h.codePolicy.Validate(record.ShortCode); err != nil {
+			h.renderURLForm(w, r, record, true, "短链接代码不符合要求", urlcheck.Errors{{Field: "short_code", Message: err.Error()}})
+			return
+		}
+	}
+	if errs := validateAliases(h.codePolicy, record); len(errs) > 0 {
+		h.renderURLForm(w, r, record, true, "别名不符合要求", errs)
 		return
 	}
 
-	// 如果短代码为空，生成随机短代码
-	if shortCode == "" {
-		code, err := GenerateRandomCode(6)
+	// 如果短代码为空，自动生成短代码
+	if record.ShortCode == "" {
+		code, err := generateCode(h.codeGen, h.codePolicy, h.cfg.CodeLength, h.urlStorage, record.Domain, nil)
 		if err != nil {
 			h.renderErrorPage(w, "错误", "生成短代码失败: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		shortCode = code
+		record.ShortCode = code
 	}
 
 	// 创建URL记录
-	err := h.urlStorage.CreateURL(storage.URLRecord{
-		ShortCode: shortCode,
-		TargetURL: targetURL,
-		Remark:    remark,
-	})
-
-	if err != nil {
-		h.renderTemplate(w, "url_form.html", map[string]interface{}{
-			"title":     "创建短链接",
-			"error":     "创建链接失败: " + err.Error(),
-			"username":  username,
-			"targetURL": targetURL,
-			"shortCode": shortCode,
-			"remark":    remark,
-			"isNew":     true,
-		})
+	if err := h.urlStorage.CreateURL(record); err != nil {
+		h.renderURLForm(w, r, record, true, "创建链接失败: "+errorMessage(err), nil)
 		return
 	}
 
@@ -349,29 +366,19 @@ func (h *AdminHTTPHandler) handleCreateURL(w http.ResponseWriter, r *http.Reques
 
 // 处理编辑URL表单
 func (h *AdminHTTPHandler) handleEditURLForm(w http.ResponseWriter, r *http.Request) {
-	username := getContextValue(r, "username").(string)
-
 	shortCode := getPathParam(r.URL.Path, `^/admin/urls/([^/]+)/edit$`)
 	if shortCode == "" {
 		h.renderErrorPage(w, "错误", "短链接代码无效", http.StatusBadRequest)
 		return
 	}
 
-	url, err := h.urlStorage.GetURLByCode(shortCode)
+	url, err := h.urlStorage.GetURL(r.URL.Query().Get("domain"), shortCode)
 	if err != nil {
-		h.renderErrorPage(w, "错误", "链接不存在: "+err.Error(), http.StatusNotFound)
+		h.renderErrorPage(w, "错误", errorMessage(err), http.StatusNotFound)
 		return
 	}
 
-	h.renderTemplate(w, "url_form.html", map[string]interface{}{
-		"title":     "编辑短链接",
-		"username":  username,
-		"isNew":     false,
-		"url":       url,
-		"shortCode": url.ShortCode,
-		"targetURL": url.TargetURL,
-		"remark":    url.Remark,
-	})
+	h.renderURLForm(w, r, *url, false, "", nil)
 }
 
 // 处理更新URL
@@ -381,48 +388,51 @@ func (h *AdminHTTPHandler) handleUpdateURL(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	username := getContextValue(r, "username").(string)
-
 	shortCode := getPathParam(r.URL.Path, `^/admin/urls/([^/]+)$`)
 	if shortCode == "" {
 		h.renderErrorPage(w, "错误", "短链接代码无效", http.StatusBadRequest)
 		return
 	}
 
-	targetURL := r.FormValue("target_url")
-	remark := r.FormValue("remark")
+	existing, err := h.urlStorage.GetURL(r.URL.Query().Get("domain"), shortCode)
+	if err != nil {
+		h.renderErrorPage(w, "错误", errorMessage(err), http.StatusNotFound)
+		return
+	}
 
-	// 验证目标URL
-	if targetURL == "" {
-		h.renderTemplate(w, "url_form.html", map[string]interface{}{
-			"title":     "编辑短链接",
-			"error":     "目标URL不能为空",
-			"username":  username,
-			"shortCode": shortCode,
-			"targetURL": targetURL,
-			"remark":    remark,
-			"isNew":     false,
-		})
+	record, err := parseURLForm(r)
+	// 通过别名访问编辑页时，按主短代码更新
+	record.ShortCode = existing.ShortCode
+	record.Domain = existing.Domain
+	if err != nil {
+		h.renderURLForm(w, r, record, false, err.Error(), nil)
 		return
 	}
 
-	// 更新URL记录
-	err := h.urlStorage.UpdateURL(storage.URLRecord{
-		ShortCode: shortCode,
-		TargetURL: targetURL,
-		Remark:    remark,
-	})
+	// 密码留空时保留原有密码
+	record.PasswordHash = existing.PasswordHash
+	if err := applyPasswordForm(r, &record); err != nil {
+		h.renderErrorPage(w, "错误", "设置访问密码失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	if err != nil {
-		h.renderTemplate(w, "url_form.html", map[string]interface{}{
-			"title":     "编辑短链接",
-			"error":     "更新链接失败: " + err.Error(),
-			"username":  username,
-			"shortCode": shortCode,
-			"targetURL": targetURL,
-			"remark":    remark,
-			"isNew":     false,
-		})
+	// 验证表单
+	if msg := validateURLForm(record); msg != "" {
+		h.renderURLForm(w, r, record, false, msg, nil)
+		return
+	}
+	if errs := validateAliases(h.codePolicy, record); len(errs) > 0 {
+		h.renderURLForm(w, r, record, false, "别名不符合要求", errs)
+		return
+	}
+	if errs := h.checkRecordURLs(r, &record); len(errs) > 0 {
+		h.renderURLForm(w, r, record, false, "请修正以下字段中的地址", errs)
+		return
+	}
+
+	// 更新URL记录
+	if err := h.urlStorage.UpdateURL(record); err != nil {
+		h.renderURLForm(w, r, record, false, "更新链接失败: "+errorMessage(err), nil)
 		return
 	}
 
@@ -430,6 +440,162 @@ func (h *AdminHTTPHandler) handleUpdateURL(w http.ResponseWriter, r *http.Reques
 	http.Redirect(w, r, "/admin", http.StatusFound)
 }
 
+// parseURLForm 从表单中读取短链接的可编辑字段（不含短代码）
+func parseURLForm(r *http.Request) (storage.URLRecord, error) {
+	record := storage.URLRecord{
+		TargetURL: r.FormValue("target_url"),
+		Remark:    r.FormValue("remark"),
+		Aliases:   parseAliases(r.FormValue("aliases")),
+
+		PassQuery:     r.FormValue("pass_query") == "on",
+		QueryConflict: r.FormValue("query_conflict"),
+		PrefixMatch:   r.FormValue("prefix_match") == "on",
+
+		MaxClicks:    parseFormInt(r.FormValue("max_clicks")),
+		ExhaustedURL: r.FormValue("exhausted_url"),
+
+		Interstitial: r.FormValue("interstitial") == "on",
+
+		OGTitle:       r.FormValue("og_title"),
+		OGDescription: r.FormValue("og_description"),
+		OGImage:       r.FormValue("og_image"),
+	}
+
+	deviceRules, err := parseDeviceRules(r.FormValue("device_rules"))
+	if err != nil {
+		return record, err
+	}
+	record.DeviceRules = deviceRules
+
+	languageRules, err := parseLanguageRules(r.FormValue("language_rules"))
+	if err != nil {
+		return record, err
+	}
+	record.LanguageRules = languageRules
+
+	variants, err := parseVariants(r.FormValue("variants"))
+	if err != nil {
+		return record, err
+	}
+	record.Variants = variants
+	record.StickyVariant = r.FormValue("sticky_variant") == "on"
+
+	return record, nil
+}
+
+// parseFormInt 解析表单中的非负整数，留空视为0，格式错误时返回-1交由校验处理
+func parseFormInt(value string) int {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// applyPasswordForm 根据表单设置或清除访问密码，密码留空时不做修改
+func applyPasswordForm(r *http.Request, record *storage.URLRecord) error {
+	if r.FormValue("clear_password") == "on" {
+		return record.SetPassword("")
+	}
+	if password := r.FormValue("password"); password != "" {
+		return record.SetPassword(password)
+	}
+	return nil
+}
+
+// validateURLForm 校验表单内容，返回错误提示，通过时返回空字符串
+func validateURLForm(record storage.URLRecord) string {
+	if record.TargetURL == "" {
+		return "目标URL不能为空"
+	}
+	if !storage.IsValidQueryConflict(record.QueryConflict) {
+		return "无效的查询参数冲突策略"
+	}
+	if record.MaxClicks < 0 {
+		return "访问次数限制必须是非负整数"
+	}
+	if msg := validateDeviceRules(record.DeviceRules); msg != "" {
+		return msg
+	}
+	if msg := validateLanguageRules(record.LanguageRules); msg != "" {
+		return msg
+	}
+	if msg := validateVariants(record.Variants); msg != "" {
+		return msg
+	}
+	return ""
+}
+
+// checkRecordURLs 依次校验地址格式、跳转链和地址信誉，校验通过时记录中的地址已规范化
+func (h *AdminHTTPHandler) checkRecordURLs(r *http.Request, record *storage.URLRecord) urlcheck.Errors {
+	if errs := normalizeRecordURLs(h.urlValidator, record); len(errs) > 0 {
+		return errs
+	}
+
+	flatten := r.FormValue("flatten_chain") == "on"
+	if errs := newChainResolver(h.urlStorage, h.cfg, r).checkRecordChains(record, flatten); len(errs) > 0 {
+		return errs
+	}
+
+	return checkRecordReputation(h.checker, record)
+}
+
+// renderURLForm 渲染新建/编辑短链接表单
+func (h *AdminHTTPHandler) renderURLForm(w http.ResponseWriter, r *http.Request, record storage.URLRecord, isNew bool, errMsg string, fieldErrors urlcheck.Errors) {
+	title := "编辑短链接"
+	if isNew {
+		title = "创建短链接"
+	}
+
+	data := map[string]interface{}{
+		"title":     title,
+		"username":  getContextValue(r, "username").(string),
+		"isNew":     isNew,
+		"record":    record,
+		"shortCode": record.ShortCode,
+		"targetURL": record.TargetURL,
+		"remark":    record.Remark,
+		"aliases":   strings.Join(record.Aliases, ", "),
+		"newCode":   r.FormValue("new_code"),
+		"domains":   h.cfg.DomainHosts(),
+
+		// 规则以文本形式编辑，提交失败时原样回显用户输入
+		"deviceRules": formValueOr(r, "device_rules", formatDeviceRules(record.DeviceRules)),
+		"platforms":   useragent.AllPlatforms,
+
+		"languageRules": formValueOr(r, "language_rules", formatLanguageRules(record.LanguageRules)),
+		"variants":      formValueOr(r, "variants", formatVariants(record.Variants)),
+	}
+	if errMsg != "" {
+		data["error"] = errMsg
+	}
+	data["fieldErrors"] = formFieldErrors(fieldErrors)
+
+	// 编辑时展示当前目标地址的跳转链
+	if !isNew && record.TargetURL != "" {
+		chain, err := newChainResolver(h.urlStorage, h.cfg, r).resolve(record.Domain, record.ShortCode, record.TargetURL)
+		if err != nil {
+			data["chainError"] = err.Error()
+		} else if chain.depth > 0 {
+			data["chainDepth"] = chain.depth
+			data["chainFinal"] = chain.final
+		}
+	}
+
+	h.renderTemplate(w, "url_form.html", data)
+}
+
+// formValueOr POST请求时返回表单中的原始值，否则返回默认值
+func formValueOr(r *http.Request, key, defaultValue string) string {
+	if r.Method == http.MethodPost {
+		return r.FormValue(key)
+	}
+	return defaultValue
+}
+
 // 处理删除URL
 func (h *AdminHTTPHandler) handleDeleteURL(w http.ResponseWriter, r *http.Request) {
 	shortCode := getPathParam(r.URL.Path, `^/admin/urls/([^/]+)/delete$`)
@@ -438,9 +604,9 @@ func (h *AdminHTTPHandler) handleDeleteURL(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err := h.urlStorage.DeleteURL(shortCode)
+	err := h.urlStorage.DeleteURL(r.URL.Query().Get("domain"), shortCode)
 	if err != nil {
-		h.renderErrorPage(w, "错误", "删除链接失败: "+err.Error(), http.StatusBadRequest)
+		h.renderErrorPage(w, "错误", "删除链接失败: "+errorMessage(err), http.StatusBadRequest)
 		return
 	}
 
@@ -448,6 +614,62 @@ func (h *AdminHTTPHandler) handleDeleteURL(w http.ResponseWriter, r *http.Reques
 	http.Redirect(w, r, "/admin", http.StatusFound)
 }
 
+// 处理修改短链接代码
+func (h *AdminHTTPHandler) handleRenameURL(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderErrorPage(w, "表单错误", "无法解析表单", http.StatusBadRequest)
+		return
+	}
+
+	shortCode := getPathParam(r.URL.Path, `^/admin/urls/([^/]+)/rename$`)
+	if shortCode == "" {
+		h.renderErrorPage(w, "错误", "短链接代码无效", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.urlStorage.GetURL(r.URL.Query().Get("domain"), shortCode)
+	if err != nil {
+		h.renderErrorPage(w, "错误", errorMessage(err), http.StatusNotFound)
+		return
+	}
+
+	newCode := strings.TrimSpace(r.FormValue("new_code"))
+	if err := h.codePolicy.Validate(newCode); err != nil {
+		h.renderURLForm(w, r, *existing, false, "新短链接代码不符合要求", urlcheck.Errors{{Field: "new_code", Message: err.Error()}})
+		return
+	}
+
+	// 旧代码默认保留为别名，选择删除时访问旧代码返回410
+	keepAlias := r.FormValue("old_code") != "tombstone"
+	renamed, err := h.urlStorage.RenamedRecord(existing.Domain, existing.ShortCode, newCode, keepAlias)
+	if err != nil {
+		h.renderURLForm(w, r, *existing, false, "修改短链接代码失败", urlcheck.Errors{{Field: "new_code", Message: errorMessage(err)}})
+		return
+	}
+
+	// 新代码可能被其他短链接指向，改名后的跳转链不能出现循环
+	errs, err := newChainResolver(h.urlStorage, h.cfg, r).checkRename(*existing, &renamed)
+	if err != nil {
+		h.renderErrorPage(w, "错误", errorMessage(err), http.StatusInternalServerError)
+		return
+	}
+	if len(errs) > 0 {
+		h.renderURLForm(w, r, *existing, false, "修改短链接代码失败", errs)
+		return
+	}
+
+	if err := h.urlStorage.RenameURL(existing.Domain, existing.ShortCode, newCode, keepAlias); err != nil {
+		h.renderURLForm(w, r, *existing, false, "修改短链接代码失败", urlcheck.Errors{{Field: "new_code", Message: errorMessage(err)}})
+		return
+	}
+
+	editURL := "/admin/urls/" + url.PathEscape(newCode) + "/edit"
+	if existing.Domain != "" {
+		editURL += "?domain=" + url.QueryEscape(existing.Domain)
+	}
+	http.Redirect(w, r, editURL, http.StatusFound)
+}
+
 // 上下文键类型，避免冲突
 type contextKey string
 
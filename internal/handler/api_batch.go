@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// 批量创建接口单次最多的链接数量
+const maxBatchSize = 10000
+
+// BatchRequest 批量创建请求体，请求体也可以直接是链接数组，此时通过查询参数all_or_nothing指定是否要求全部成功
+type BatchRequest struct {
+	Links []APIRequest `json:"links"`
+
+	// 任一链接失败时都不创建
+	AllOrNothing bool `json:"all_or_nothing,omitempty"`
+}
+
+// BatchResult 单个链接的创建结果，成功时Link为创建或复用的短链接，失败时Error为错误信息
+type BatchResult struct {
+	Index int          `json:"index"`
+	Link  *APIResponse `json:"link,omitempty"`
+	Error *APIError    `json:"error,omitempty"`
+}
+
+// BatchResponse 批量创建响应体，Results与请求中的链接一一对应
+type BatchResponse struct {
+	Created int           `json:"created"`
+	Failed  int           `json:"failed"`
+	Results []BatchResult `json:"results"`
+}
+
+// createBatch 批量校验并创建短链接，所有链接在同一次存储操作中写入
+func (h *APIHTTPHandler) createBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	request, apiErr := parseBatchRequest(r, body)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if len(request.Links) == 0 {
+		writeAPIError(w, validationError("links", "链接列表不能为空"))
+		return
+	}
+	if len(request.Links) > maxBatchSize {
+		writeAPIError(w, validationError("links", fmt.Sprintf("单次最多创建%d个链接", maxBatchSize)))
+		return
+	}
+
+	results := make([]BatchResult, len(request.Links))
+	var records []storage.URLRecord
+	var indexes []int
+
+	// 同一批次中自动生成的短代码不能重复
+	pending := make(map[string]map[string]bool)
+	for i, item := range request.Links {
+		results[i].Index = i
+
+		record, apiErr := h.prepareRecord(r, item)
+		if apiErr != nil {
+			results[i].Error = apiErr
+			continue
+		}
+
+		if item.ReuseExisting {
+			if existing := h.findReusable(item, record); existing != nil {
+				response := newAPIResponse(r, *existing)
+				response.Reused = true
+				results[i].Link = &response
+				continue
+			}
+		}
+
+		if pending[record.Domain] == nil {
+			pending[record.Domain] = make(map[string]bool)
+		}
+		if apiErr := h.completeRecord(item, &record, pending[record.Domain]); apiErr != nil {
+			results[i].Error = apiErr
+			continue
+		}
+		pending[record.Domain][record.ShortCode] = true
+
+		records = append(records, record)
+		indexes = append(indexes, i)
+	}
+
+	// 校验阶段已有失败且要求全部成功时不写入存储
+	if !(request.AllOrNothing && hasBatchError(results)) && len(records) > 0 {
+		errs := h.urlStorage.CreateURLs(records, request.AllOrNothing)
+		for j, err := range errs {
+			i := indexes[j]
+			if err != nil {
+				results[i].Error = storageError(err)
+				continue
+			}
+			response := newAPIResponse(r, records[j])
+			results[i].Link = &response
+		}
+	}
+
+	// 整批取消时，复用的链接也不计为成功
+	if request.AllOrNothing && hasBatchError(results) {
+		for i := range results {
+			if results[i].Error == nil {
+				results[i].Link = nil
+				results[i].Error = storageError(storage.ErrBatchAborted)
+			}
+		}
+	}
+
+	response := BatchResponse{Results: results}
+	for _, result := range results {
+		if result.Error != nil {
+			response.Failed++
+		} else {
+			response.Created++
+		}
+	}
+
+	// 要求全部成功时，有失败即整体返回400
+	status := http.StatusOK
+	if request.AllOrNothing && response.Failed > 0 {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, response)
+}
+
+// hasBatchError 判断是否有链接失败
+func hasBatchError(results []BatchResult) bool {
+	for _, result := range results {
+		if result.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBatchRequest 解析批量请求，支持{"links": [...]}和直接的链接数组两种格式，
+// 查询参数all_or_nothing=true对两种格式都生效
+func parseBatchRequest(r *http.Request, body []byte) (BatchRequest, *APIError) {
+	var request BatchRequest
+	var err error
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &request.Links)
+	} else {
+		err = json.Unmarshal(body, &request)
+	}
+	if err != nil {
+		return BatchRequest{}, newAPIError(http.StatusBadRequest, ErrorCodeInvalidJSON, "无效的请求格式")
+	}
+
+	if value := r.URL.Query().Get("all_or_nothing"); value != "" {
+		allOrNothing, err := strconv.ParseBool(value)
+		if err != nil {
+			return BatchRequest{}, validationError("all_or_nothing", "all_or_nothing必须是true或false")
+		}
+		request.AllOrNothing = request.AllOrNothing || allOrNothing
+	}
+	return request, nil
+}
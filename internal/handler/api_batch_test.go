@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestParseBatchRequest 批量请求支持对象和数组两种格式，查询参数all_or_nothing对两种格式都生效
+func TestParseBatchRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		body         string
+		links        int
+		allOrNothing bool
+		errCode      string
+	}{
+		{name: "对象", body: `{"links":[{"target_url":"https://example.com/"}],"all_or_nothing":true}`, links: 1, allOrNothing: true},
+		{name: "数组", body: ` [{"target_url":"https://example.com/1"},{"target_url":"https://example.com/2"}]`, links: 2},
+		{name: "数组和查询参数", query: "?all_or_nothing=true", body: `[{"target_url":"https://example.com/"}]`, links: 1, allOrNothing: true},
+		{name: "对象和查询参数", query: "?all_or_nothing=1", body: `{"links":[{"target_url":"https://example.com/"}]}`, links: 1, allOrNothing: true},
+		{name: "无效的查询参数", query: "?all_or_nothing=maybe", body: `[]`, errCode: ErrorCodeValidationFailed},
+		{name: "无效的JSON", body: `[{`, errCode: ErrorCodeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, BatchPath+tt.query, nil)
+			request, apiErr := parseBatchRequest(r, []byte(tt.body))
+			if tt.errCode != "" {
+				if apiErr == nil || apiErr.Code != tt.errCode {
+					t.Fatalf("错误代码应为%s，实际为%+v", tt.errCode, apiErr)
+				}
+				return
+			}
+			if apiErr != nil {
+				t.Fatalf("解析失败: %+v", apiErr)
+			}
+			if len(request.Links) != tt.links || request.AllOrNothing != tt.allOrNothing {
+				t.Errorf("应解析出%d个链接、all_or_nothing=%v，实际为%d个、%v", tt.links, tt.allOrNothing, len(request.Links), request.AllOrNothing)
+			}
+		})
+	}
+}
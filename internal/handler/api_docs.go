@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// openAPISpec 渲染文档页面用到的OpenAPI文档字段
+type openAPISpec struct {
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Parameters map[string]openAPIParameter `json:"parameters"`
+		Responses  map[string]openAPIResponse  `json:"responses"`
+		Schemas    map[string]openAPISchema    `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Summary     string             `json:"summary"`
+	Description string             `json:"description"`
+	Security    *[]json.RawMessage `json:"security"` // 为空数组表示无需认证，未设置时使用全局认证
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema openAPISchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Ref         string        `json:"$ref"`
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Required    bool          `json:"required"`
+	Description string        `json:"description"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Ref         string `json:"$ref"`
+	Description string `json:"description"`
+	Content     map[string]struct {
+		Schema openAPISchema `json:"schema"`
+	} `json:"content"`
+}
+
+type openAPISchema struct {
+	Ref         string                   `json:"$ref"`
+	Type        string                   `json:"type"`
+	Format      string                   `json:"format"`
+	Description string                   `json:"description"`
+	Enum        []interface{}            `json:"enum"`
+	Items       *openAPISchema           `json:"items"`
+	OneOf       []openAPISchema          `json:"oneOf"`
+	Properties  map[string]openAPISchema `json:"properties"`
+	Required    []string                 `json:"required"`
+	ReadOnly    bool                     `json:"readOnly"`
+}
+
+// apiDocs 文档页面的内容，由OpenAPI文档生成，页面不依赖外部脚本
+type apiDocs struct {
+	Title       string
+	Description string
+	Version     string
+	Operations  []apiDocsOperation
+	Schemas     []apiDocsSchema
+}
+
+type apiDocsOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Auth        bool
+	Parameters  []apiDocsParameter
+	RequestBody []apiDocsType
+	Responses   []apiDocsResponse
+}
+
+type apiDocsParameter struct {
+	Name        string
+	In          string
+	Type        apiDocsType
+	Required    bool
+	Description string
+}
+
+type apiDocsResponse struct {
+	Status      string
+	Description string
+	Types       []apiDocsType
+}
+
+type apiDocsSchema struct {
+	Name        string
+	Description string
+	Type        apiDocsType // 非对象类型（如数组）的类型
+	Properties  []apiDocsProperty
+}
+
+type apiDocsProperty struct {
+	Name        string
+	Type        apiDocsType
+	Required    bool
+	ReadOnly    bool
+	Description string
+	Enum        []string
+}
+
+// apiDocsType 字段类型，Schema不为空时链接到对应的结构定义
+type apiDocsType struct {
+	Label  string
+	Schema string
+}
+
+// 接口在页面中的排列顺序
+var apiDocsMethodOrder = map[string]int{"get": 0, "post": 1, "put": 2, "patch": 3, "delete": 4}
+
+// buildAPIDocs 解析OpenAPI文档，生成文档页面的内容
+func buildAPIDocs(data []byte) (*apiDocs, error) {
+	var spec openAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	docs := &apiDocs{
+		Title:       spec.Info.Title,
+		Description: spec.Info.Description,
+		Version:     spec.Info.Version,
+	}
+
+	for path, operations := range spec.Paths {
+		for method, op := range operations {
+			operation := apiDocsOperation{
+				Method:      strings.ToUpper(method),
+				Path:        path,
+				Summary:     op.Summary,
+				Description: op.Description,
+				Auth:        op.Security == nil || len(*op.Security) > 0,
+			}
+
+			for _, p := range op.Parameters {
+				if p.Ref != "" {
+					resolved, ok := spec.Components.Parameters[refName(p.Ref)]
+					if !ok {
+						return nil, fmt.Errorf("参数 %s 不存在", p.Ref)
+					}
+					p = resolved
+				}
+				operation.Parameters = append(operation.Parameters, apiDocsParameter{
+					Name:        p.Name,
+					In:          p.In,
+					Type:        schemaType(p.Schema),
+					Required:    p.Required,
+					Description: p.Description,
+				})
+			}
+
+			if op.RequestBody != nil {
+				for _, content := range op.RequestBody.Content {
+					operation.RequestBody = append(operation.RequestBody, schemaTypes(content.Schema)...)
+				}
+			}
+
+			for status, r := range op.Responses {
+				if r.Ref != "" {
+					resolved, ok := spec.Components.Responses[refName(r.Ref)]
+					if !ok {
+						return nil, fmt.Errorf("响应 %s 不存在", r.Ref)
+					}
+					r = resolved
+				}
+				response := apiDocsResponse{Status: status, Description: r.Description}
+				for _, content := range r.Content {
+					response.Types = append(response.Types, schemaTypes(content.Schema)...)
+				}
+				operation.Responses = append(operation.Responses, response)
+			}
+			sort.Slice(operation.Responses, func(i, j int) bool {
+				return operation.Responses[i].Status < operation.Responses[j].Status
+			})
+
+			docs.Operations = append(docs.Operations, operation)
+		}
+	}
+	sort.Slice(docs.Operations, func(i, j int) bool {
+		a, b := docs.Operations[i], docs.Operations[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return apiDocsMethodOrder[strings.ToLower(a.Method)] < apiDocsMethodOrder[strings.ToLower(b.Method)]
+	})
+
+	for name, s := range spec.Components.Schemas {
+		schema := apiDocsSchema{Name: name, Description: s.Description}
+		if s.Type != "object" {
+			schema.Type = schemaType(s)
+		}
+
+		required := make(map[string]bool)
+		for _, field := range s.Required {
+			required[field] = true
+		}
+		for field, p := range s.Properties {
+			property := apiDocsProperty{
+				Name:        field,
+				Type:        schemaType(p),
+				Required:    required[field],
+				ReadOnly:    p.ReadOnly,
+				Description: p.Description,
+			}
+			for _, value := range p.Enum {
+				property.Enum = append(property.Enum, fmt.Sprint(value))
+			}
+			schema.Properties = append(schema.Properties, property)
+		}
+		// 必填字段在前，其余按名称排序
+		sort.Slice(schema.Properties, func(i, j int) bool {
+			a, b := schema.Properties[i], schema.Properties[j]
+			if a.Required != b.Required {
+				return a.Required
+			}
+			return a.Name < b.Name
+		})
+		docs.Schemas = append(docs.Schemas, schema)
+	}
+	sort.Slice(docs.Schemas, func(i, j int) bool {
+		return docs.Schemas[i].Name < docs.Schemas[j].Name
+	})
+
+	return docs, nil
+}
+
+// schemaTypes 返回结构可能的类型，oneOf时每个候选类型各一项
+func schemaTypes(s openAPISchema) []apiDocsType {
+	if len(s.OneOf) == 0 {
+		return []apiDocsType{schemaType(s)}
+	}
+	var types []apiDocsType
+	for _, option := range s.OneOf {
+		types = append(types, schemaType(option))
+	}
+	return types
+}
+
+// schemaType 生成字段类型的展示文本
+func schemaType(s openAPISchema) apiDocsType {
+	switch {
+	case s.Ref != "":
+		name := refName(s.Ref)
+		return apiDocsType{Label: name, Schema: name}
+	case s.Type == "array" && s.Items != nil:
+		item := schemaType(*s.Items)
+		return apiDocsType{Label: item.Label + "[]", Schema: item.Schema}
+	case s.Format != "":
+		return apiDocsType{Label: s.Type + " (" + s.Format + ")"}
+	}
+	return apiDocsType{Label: s.Type}
+}
+
+// refName 返回引用的组件名称，如 #/components/schemas/APIRequest 返回 APIRequest
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
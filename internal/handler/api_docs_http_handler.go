@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	html_templates "github.com/yu1ec/go-shorten/templates"
+)
+
+// API文档路径
+const (
+	OpenAPIPath = "/api/openapi.json"
+	APIDocsPath = "/api/docs"
+)
+
+// APIDocsHTTPHandler 提供OpenAPI文档和在线文档页面，无需认证
+// 文档页面由内嵌的OpenAPI文档在服务端生成，不加载外部脚本，内网和离线部署同样可用
+type APIDocsHTTPHandler struct {
+	templates map[string]*template.Template
+	docs      *apiDocs
+}
+
+// NewAPIDocsHTTPHandler 创建API文档处理器
+func NewAPIDocsHTTPHandler() *APIDocsHTTPHandler {
+	docs, err := buildAPIDocs(html_templates.OpenAPISpec)
+	if err != nil {
+		log.Fatalf("解析OpenAPI文档失败: %v", err)
+	}
+	return &APIDocsHTTPHandler{
+		templates: parseStandaloneTemplates("api_docs.html"),
+		docs:      docs,
+	}
+}
+
+// ServeHTTP 实现http.Handler接口
+func (h *APIDocsHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, newAPIError(http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, "方法不被允许"))
+		return
+	}
+
+	switch r.URL.Path {
+	case OpenAPIPath:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(html_templates.OpenAPISpec)
+	case APIDocsPath:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.templates["api_docs.html"].Execute(w, map[string]interface{}{"specURL": OpenAPIPath, "docs": h.docs}); err != nil {
+			log.Printf("渲染API文档页面失败: %v\n", err)
+		}
+	default:
+		writeAPIError(w, newAPIError(http.StatusNotFound, ErrorCodeNotFound, "接口不存在"))
+	}
+}
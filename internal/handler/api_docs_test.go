@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	html_templates "github.com/yu1ec/go-shorten/templates"
+)
+
+// openAPIDocument 测试用到的OpenAPI文档字段
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components map[string]map[string]json.RawMessage `json:"components"`
+}
+
+func loadOpenAPIDocument(t *testing.T) openAPIDocument {
+	t.Helper()
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(html_templates.OpenAPISpec, &doc); err != nil {
+		t.Fatalf("解析OpenAPI文档失败: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("OpenAPI版本应为3.x，实际为%q", doc.OpenAPI)
+	}
+	return doc
+}
+
+// registeredAPIRoutes 返回RegisterAPIRoutes注册的所有路径及对应的HTTP方法，不包括/api/兜底路径
+func registeredAPIRoutes(t *testing.T) map[string]string {
+	t.Helper()
+
+	routes := make(map[string]string)
+	register := func(pattern string, h http.Handler) {
+		switch {
+		case pattern == "/api/":
+		case !strings.HasPrefix(pattern, "/api/"):
+			t.Errorf("注册了非API路径 %s", pattern)
+		default:
+			switch h.(type) {
+			case *APIHTTPHandler:
+				routes[pattern] = "post"
+			case *APIDocsHTTPHandler:
+				routes[pattern] = "get"
+			default:
+				t.Errorf("路径 %s 注册了未知的处理器 %T", pattern, h)
+			}
+		}
+	}
+	RegisterAPIRoutes(register, &APIHTTPHandler{}, &APIDocsHTTPHandler{})
+	return routes
+}
+
+// TestOpenAPICoversRoutes 每个已注册的API路径都必须在OpenAPI文档中描述，文档中的路径也必须已注册
+func TestOpenAPICoversRoutes(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+	routes := registeredAPIRoutes(t)
+
+	for path, method := range routes {
+		operations, exists := doc.Paths[path]
+		if !exists {
+			t.Errorf("OpenAPI文档缺少接口 %s", path)
+			continue
+		}
+		if _, exists := operations[method]; !exists {
+			t.Errorf("OpenAPI文档缺少接口 %s %s", strings.ToUpper(method), path)
+		}
+	}
+
+	for path := range doc.Paths {
+		if _, exists := routes[path]; !exists {
+			t.Errorf("OpenAPI文档中的接口 %s 未注册", path)
+		}
+	}
+}
+
+// TestOpenAPIReferences 文档中引用的组件都必须存在
+func TestOpenAPIReferences(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+
+	refPattern := regexp.MustCompile(`"\$ref":\s*"#/components/([^/"]+)/([^"]+)"`)
+	for _, match := range refPattern.FindAllStringSubmatch(string(html_templates.OpenAPISpec), -1) {
+		if _, exists := doc.Components[match[1]][match[2]]; !exists {
+			t.Errorf("OpenAPI文档引用的组件 %s/%s 不存在", match[1], match[2])
+		}
+	}
+}
+
+// TestAPIDocsPage 文档页面由内嵌文档生成，包含所有接口和数据结构，且不加载外部脚本
+func TestAPIDocsPage(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+
+	w := httptest.NewRecorder()
+	NewAPIDocsHTTPHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, APIDocsPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("文档页面应返回200，实际为%d", w.Code)
+	}
+
+	page := w.Body.String()
+	if strings.Contains(page, "<script") {
+		t.Error("文档页面不应加载脚本")
+	}
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			if !strings.Contains(page, `id="`+strings.ToUpper(method)+"-"+path+`"`) {
+				t.Errorf("文档页面缺少接口 %s %s", strings.ToUpper(method), path)
+			}
+		}
+	}
+	for name := range doc.Components["schemas"] {
+		if !strings.Contains(page, `id="schema-`+name+`"`) {
+			t.Errorf("文档页面缺少数据结构 %s", name)
+		}
+	}
+}
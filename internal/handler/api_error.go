@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/urlcheck"
+)
+
+// 错误代码，客户端据此判断错误类型，不随提示文字变化
+const (
+	ErrorCodeUnauthorized          = "unauthorized"
+	ErrorCodeMethodNotAllowed      = "method_not_allowed"
+	ErrorCodeInvalidJSON           = "invalid_json"
+	ErrorCodeValidationFailed      = "validation_failed"
+	ErrorCodeRequestTooLarge       = "request_too_large"
+	ErrorCodeNotFound              = "not_found"
+	ErrorCodeConflict              = "conflict"
+	ErrorCodeBatchAborted          = "batch_aborted"
+	ErrorCodeIdempotencyMismatch   = "idempotency_key_mismatch"
+	ErrorCodeIdempotencyInProgress = "idempotency_key_in_progress"
+	ErrorCodeInternal              = "internal_error"
+)
+
+// ErrorDetail 字段级错误，Field为请求中的字段路径，如 variants[0].target_url
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// APIError API错误信息
+type APIError struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details []ErrorDetail `json:"details,omitempty"`
+
+	status int
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// ErrorResponse API错误响应体
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// newAPIError 创建API错误
+func newAPIError(status int, code, message string) *APIError {
+	return &APIError{Code: code, Message: message, status: status}
+}
+
+// validationError 创建与单个字段相关的校验错误，field为空表示不针对具体字段
+func validationError(field, message string) *APIError {
+	e := newAPIError(http.StatusBadRequest, ErrorCodeValidationFailed, message)
+	if field != "" {
+		e.Details = []ErrorDetail{{Field: field, Message: message}}
+	}
+	return e
+}
+
+// fieldErrors 将字段错误列表转换为校验错误
+func fieldErrors(errs urlcheck.Errors) *APIError {
+	e := newAPIError(http.StatusBadRequest, ErrorCodeValidationFailed, errs.Error())
+	for _, fe := range errs {
+		e.Details = append(e.Details, ErrorDetail{Field: fe.Field, Message: fe.Message})
+	}
+	return e
+}
+
+// storageError 将存储层错误转换为对应状态码的API错误
+func storageError(err error) *APIError {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return newAPIError(http.StatusNotFound, ErrorCodeNotFound, errorMessage(err))
+	case errors.Is(err, storage.ErrConflict):
+		return newAPIError(http.StatusConflict, ErrorCodeConflict, errorMessage(err))
+	case errors.Is(err, storage.ErrInvalid):
+		return newAPIError(http.StatusBadRequest, ErrorCodeValidationFailed, errorMessage(err))
+	case errors.Is(err, storage.ErrBatchAborted):
+		return newAPIError(http.StatusConflict, ErrorCodeBatchAborted, errorMessage(err))
+	default:
+		return newAPIError(http.StatusInternalServerError, ErrorCodeInternal, "保存链接失败")
+	}
+}
+
+// writeAPIError 写入JSON格式的错误响应
+func writeAPIError(w http.ResponseWriter, e *APIError) {
+	writeJSON(w, e.status, ErrorResponse{Error: e})
+}
@@ -2,10 +2,20 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
 
 	"github.com/yu1ec/go-shorten/internal/auth"
+	"github.com/yu1ec/go-shorten/internal/codegen"
+	"github.com/yu1ec/go-shorten/internal/codepolicy"
+	"github.com/yu1ec/go-shorten/internal/config"
+	"github.com/yu1ec/go-shorten/internal/idempotency"
+	"github.com/yu1ec/go-shorten/internal/reputation"
 	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/urlcheck"
 )
 
 // APIRequest API请求体
@@ -13,104 +23,463 @@ type APIRequest struct {
 	TargetURL string `json:"target_url"`
 	ShortCode string `json:"short_code,omitempty"`
 	Remark    string `json:"remark,omitempty"`
+
+	// 别名，访问别名等同于访问短代码
+	Aliases []string `json:"aliases,omitempty"`
+
+	// 短链接所属域名，为空表示默认域名
+	Domain string `json:"domain,omitempty"`
+
+	// 目标地址指向本站短链接时，直接保存跳转链的最终地址
+	FlattenChain bool `json:"flatten_chain,omitempty"`
+
+	// 已有相同目标地址的短链接时直接返回，不再创建
+	ReuseExisting bool `json:"reuse_existing,omitempty"`
+
+	// 跳转选项
+	PassQuery     bool   `json:"pass_query,omitempty"`
+	QueryConflict string `json:"query_conflict,omitempty"`
+	PrefixMatch   bool   `json:"prefix_match,omitempty"`
+
+	// 访问密码，为空表示不设置
+	Password string `json:"password,omitempty"`
+
+	// 访问次数限制，0表示不限次数
+	MaxClicks    int    `json:"max_clicks,omitempty"`
+	ExhaustedURL string `json:"exhausted_url,omitempty"`
+
+	Interstitial bool `json:"interstitial,omitempty"`
+
+	// 设备平台跳转规则
+	DeviceRules []storage.DeviceRule `json:"device_rules,omitempty"`
+
+	// 语言跳转规则
+	LanguageRules []storage.LanguageRule `json:"language_rules,omitempty"`
+
+	// A/B 分流版本
+	Variants      []storage.Variant `json:"variants,omitempty"`
+	StickyVariant bool              `json:"sticky_variant,omitempty"`
+
+	// 社交平台链接预览
+	OGTitle       string `json:"og_title,omitempty"`
+	OGDescription string `json:"og_description,omitempty"`
+	OGImage       string `json:"og_image,omitempty"`
 }
 
 // APIResponse API响应体
 type APIResponse struct {
+	Domain     string `json:"domain,omitempty"`
 	ShortCode  string `json:"short_code"`
 	TargetURL  string `json:"target_url"`
 	ShortURL   string `json:"short_url,omitempty"`
+	QRCodeURL  string `json:"qr_code_url,omitempty"`
 	Remark     string `json:"remark,omitempty"`
 	CreateTime string `json:"create_time,omitempty"`
+
+	Aliases []string `json:"aliases,omitempty"`
+
+	// 返回的是已有的短链接
+	Reused bool `json:"reused,omitempty"`
+
+	PassQuery     bool   `json:"pass_query,omitempty"`
+	QueryConflict string `json:"query_conflict,omitempty"`
+	PrefixMatch   bool   `json:"prefix_match,omitempty"`
+
+	PasswordProtected bool `json:"password_protected,omitempty"`
+
+	MaxClicks    int    `json:"max_clicks,omitempty"`
+	ExhaustedURL string `json:"exhausted_url,omitempty"`
+
+	Interstitial bool `json:"interstitial,omitempty"`
+
+	// 设备平台跳转规则
+	DeviceRules []storage.DeviceRule `json:"device_rules,omitempty"`
+
+	// 语言跳转规则
+	LanguageRules []storage.LanguageRule `json:"language_rules,omitempty"`
+
+	// A/B 分流版本
+	Variants      []storage.Variant `json:"variants,omitempty"`
+	StickyVariant bool              `json:"sticky_variant,omitempty"`
+
+	// 社交平台链接预览
+	OGTitle       string `json:"og_title,omitempty"`
+	OGDescription string `json:"og_description,omitempty"`
+	OGImage       string `json:"og_image,omitempty"`
+}
+
+// Idempotency-Key的最大长度
+const maxIdempotencyKeyLength = 255
+
+// 请求体的最大字节数，批量接口允许更大的请求体
+const (
+	maxRequestBodySize = 1 << 20
+	maxBatchBodySize   = 16 << 20
+)
+
+// API接口路径
+const (
+	ShortenPath = "/api/shorten"
+	BatchPath   = "/api/v1/links:batch"
+)
+
+// apiRoutes API接口路径及对应的处理方法，新增接口时需同步更新OpenAPI文档
+var apiRoutes = map[string]func(*APIHTTPHandler, http.ResponseWriter, *http.Request, []byte){
+	ShortenPath: (*APIHTTPHandler).create,
+	BatchPath:   (*APIHTTPHandler).createBatch,
+}
+
+// RegisterAPIRoutes 注册所有API接口和文档页面，register通常调用http.ServeMux.Handle，
+// 未注册的/api/路径由apiHandler返回JSON格式的404
+func RegisterAPIRoutes(register func(pattern string, h http.Handler), apiHandler *APIHTTPHandler, docsHandler *APIDocsHTTPHandler) {
+	paths := make([]string, 0, len(apiRoutes))
+	for path := range apiRoutes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		register(path, apiHandler)
+	}
+
+	register(OpenAPIPath, docsHandler)
+	register(APIDocsPath, docsHandler)
+	register("/api/", apiHandler)
 }
 
 // APIHTTPHandler API处理器
 type APIHTTPHandler struct {
-	urlStorage  *storage.URLStorage
-	userManager *auth.UserManager
+	urlStorage   *storage.URLStorage
+	userManager  *auth.UserManager
+	cfg          *config.Config
+	urlValidator *urlcheck.Validator
+	checker      reputation.Checker
+	codeGen      codegen.Generator
+	codePolicy   *codepolicy.Policy
+	idempotency  *idempotency.Store
 }
 
 // NewAPIHTTPHandler 创建API处理器
-func NewAPIHTTPHandler(urlStorage *storage.URLStorage, userManager *auth.UserManager) *APIHTTPHandler {
+func NewAPIHTTPHandler(urlStorage *storage.URLStorage, userManager *auth.UserManager, cfg *config.Config, checker reputation.Checker, codeGen codegen.Generator, codePolicy *codepolicy.Policy) *APIHTTPHandler {
+	idempotencyStore := idempotency.NewStore(cfg.IdempotencyTTL)
+	idempotencyStore.StartGCTimer()
+
 	return &APIHTTPHandler{
-		urlStorage:  urlStorage,
-		userManager: userManager,
+		urlStorage:   urlStorage,
+		userManager:  userManager,
+		cfg:          cfg,
+		urlValidator: newURLValidator(cfg),
+		checker:      checker,
+		codeGen:      codeGen,
+		codePolicy:   codePolicy,
+		idempotency:  idempotencyStore,
 	}
 }
 
 // ServeHTTP 实现http.Handler接口
 func (h *APIHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := apiRoutes[r.URL.Path]
+	if !ok {
+		writeAPIError(w, newAPIError(http.StatusNotFound, ErrorCodeNotFound, "接口不存在"))
+		return
+	}
+	serve := func(w http.ResponseWriter, r *http.Request, body []byte) {
+		route(h, w, r, body)
+	}
+
 	// 基本认证
 	username, password, ok := r.BasicAuth()
 	if !ok || !h.userManager.AuthenticateBasic(username, password) {
 		w.Header().Set("WWW-Authenticate", "Basic realm=\"Authorization Required\"")
-		http.Error(w, "未授权", http.StatusUnauthorized)
+		writeAPIError(w, newAPIError(http.StatusUnauthorized, ErrorCodeUnauthorized, "未授权"))
 		return
 	}
 
 	// 只处理POST请求
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不被允许", http.StatusMethodNotAllowed)
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, newAPIError(http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, "方法不被允许"))
+		return
+	}
+
+	// 限制请求体大小，避免超大请求占用内存
+	limit := int64(maxRequestBodySize)
+	if r.URL.Path == BatchPath {
+		limit = maxBatchBodySize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAPIError(w, newAPIError(http.StatusRequestEntityTooLarge, ErrorCodeRequestTooLarge, fmt.Sprintf("请求体不能超过%dMB", limit>>20)))
+			return
+		}
+		writeAPIError(w, newAPIError(http.StatusBadRequest, ErrorCodeInvalidJSON, "读取请求失败"))
 		return
 	}
 
+	// 带幂等键的请求，重试时返回首次请求的响应
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if len(key) > maxIdempotencyKeyLength {
+			writeAPIError(w, validationError("Idempotency-Key", "Idempotency-Key过长"))
+			return
+		}
+		h.serveIdempotent(w, r, username+"\x00"+r.URL.Path+"\x00"+key, body, serve)
+		return
+	}
+
+	serve(w, r, body)
+}
+
+// serveIdempotent 处理带Idempotency-Key的创建请求，幂等键按用户和接口区分
+func (h *APIHTTPHandler) serveIdempotent(w http.ResponseWriter, r *http.Request, key string, body []byte, serve func(http.ResponseWriter, *http.Request, []byte)) {
+	// 查询参数（如批量接口的all_or_nothing）同样影响结果，一并计入请求内容
+	saved, err := h.idempotency.Begin(key, append([]byte(r.URL.RawQuery+"\x00"), body...))
+	switch {
+	case errors.Is(err, idempotency.ErrMismatch):
+		writeAPIError(w, newAPIError(http.StatusUnprocessableEntity, ErrorCodeIdempotencyMismatch, errorMessage(err)))
+		return
+	case errors.Is(err, idempotency.ErrInProgress):
+		writeAPIError(w, newAPIError(http.StatusConflict, ErrorCodeIdempotencyInProgress, errorMessage(err)))
+		return
+	case saved != nil:
+		w.Header().Set("Content-Type", saved.ContentType)
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(saved.Status)
+		w.Write(saved.Body)
+		return
+	}
+
+	// 处理过程中panic时同样释放占用的键，已保存响应的键不受影响
+	defer h.idempotency.Release(key)
+
+	recorder := idempotency.NewRecorder(w)
+	serve(recorder, r, body)
+
+	// 服务端错误不保存，允许客户端用同一幂等键重试
+	response := recorder.Response()
+	if response.Status >= http.StatusInternalServerError {
+		return
+	}
+	h.idempotency.Complete(key, response)
+}
+
+// create 校验请求并创建短链接
+func (h *APIHTTPHandler) create(w http.ResponseWriter, r *http.Request, body []byte) {
 	// 解析JSON请求体
 	var request APIRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "无效的请求格式", http.StatusBadRequest)
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeAPIError(w, newAPIError(http.StatusBadRequest, ErrorCodeInvalidJSON, "无效的请求格式"))
 		return
 	}
 
-	// 验证目标URL
-	if request.TargetURL == "" {
-		http.Error(w, "目标URL不能为空", http.StatusBadRequest)
+	record, apiErr := h.prepareRecord(r, request)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 
-	// 如果短代码为空，生成随机短代码
-	if request.ShortCode == "" {
-		code, err := GenerateRandomCode(6)
-		if err != nil {
-			http.Error(w, "生成短代码失败", http.StatusInternalServerError)
+	// 复用已有的短链接
+	if request.ReuseExisting {
+		if existing := h.findReusable(request, record); existing != nil {
+			response := newAPIResponse(r, *existing)
+			response.Reused = true
+			writeAPIResponse(w, response)
 			return
 		}
-		request.ShortCode = code
 	}
 
-	// 创建URL记录
-	err := h.urlStorage.CreateURL(storage.URLRecord{
-		ShortCode: request.ShortCode,
-		TargetURL: request.TargetURL,
-		Remark:    request.Remark,
-	})
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if apiErr := h.completeRecord(request, &record, nil); apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 
-	// 获取完整的短链接URL
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
+	// 创建URL记录
+	if err := h.urlStorage.CreateURL(record); err != nil {
+		writeAPIError(w, storageError(err))
+		return
 	}
-	shortURL := scheme + "://" + r.Host + "/" + request.ShortCode
 
 	// 返回结果
-	response := APIResponse{
+	writeAPIResponse(w, newAPIResponse(r, record))
+}
+
+// prepareRecord 校验请求并生成待保存的记录，地址字段已规范化，尚未设置密码和短代码
+func (h *APIHTTPHandler) prepareRecord(r *http.Request, request APIRequest) (storage.URLRecord, *APIError) {
+	// 验证目标URL
+	if request.TargetURL == "" {
+		return storage.URLRecord{}, validationError("target_url", "目标URL不能为空")
+	}
+
+	// 验证自定义短代码
+	if request.ShortCode != "" {
+		if err := h.codePolicy.Validate(request.ShortCode); err != nil {
+			return storage.URLRecord{}, fieldErrors(urlcheck.Errors{{Field: "short_code", Message: err.Error()}})
+		}
+	}
+
+	// 验证域名
+	request.Domain = storage.NormalizeDomain(request.Domain)
+	if request.Domain != "" && h.cfg.Domain(request.Domain) == nil {
+		return storage.URLRecord{}, validationError("domain", "域名未配置")
+	}
+
+	// 验证查询参数冲突策略
+	if !storage.IsValidQueryConflict(request.QueryConflict) {
+		return storage.URLRecord{}, validationError("query_conflict", "无效的查询参数冲突策略")
+	}
+
+	// 验证访问次数限制
+	if request.MaxClicks < 0 {
+		return storage.URLRecord{}, validationError("max_clicks", "访问次数限制必须是非负整数")
+	}
+
+	// 验证设备平台规则
+	if msg := validateDeviceRules(request.DeviceRules); msg != "" {
+		return storage.URLRecord{}, validationError("device_rules", msg)
+	}
+
+	// 验证语言规则
+	if msg := validateLanguageRules(request.LanguageRules); msg != "" {
+		return storage.URLRecord{}, validationError("language_rules", msg)
+	}
+
+	// 验证A/B版本
+	if msg := validateVariants(request.Variants); msg != "" {
+		return storage.URLRecord{}, validationError("variants", msg)
+	}
+
+	record := storage.URLRecord{
+		Domain:    request.Domain,
 		ShortCode: request.ShortCode,
 		TargetURL: request.TargetURL,
-		ShortURL:  shortURL,
 		Remark:    request.Remark,
+		Aliases:   request.Aliases,
+
+		PassQuery:     request.PassQuery,
+		QueryConflict: request.QueryConflict,
+		PrefixMatch:   request.PrefixMatch,
+
+		MaxClicks:    request.MaxClicks,
+		ExhaustedURL: request.ExhaustedURL,
+
+		Interstitial: request.Interstitial,
+
+		DeviceRules:   request.DeviceRules,
+		LanguageRules: request.LanguageRules,
+
+		Variants:      request.Variants,
+		StickyVariant: request.StickyVariant,
+
+		OGTitle:       request.OGTitle,
+		OGDescription: request.OGDescription,
+		OGImage:       request.OGImage,
+	}
+
+	// 验证别名
+	if errs := validateAliases(h.codePolicy, record); len(errs) > 0 {
+		return record, fieldErrors(errs)
+	}
+
+	// 校验并规范化所有地址字段
+	if errs := normalizeRecordURLs(h.urlValidator, &record); len(errs) > 0 {
+		return record, fieldErrors(errs)
 	}
 
+	// 检查指向本站短链接的跳转链
+	if errs := newChainResolver(h.urlStorage, h.cfg, r).checkRecordChains(&record, request.FlattenChain); len(errs) > 0 {
+		return record, fieldErrors(errs)
+	}
+
+	// 检查地址信誉
+	if errs := checkRecordReputation(h.checker, &record); len(errs) > 0 {
+		return record, fieldErrors(errs)
+	}
+
+	return record, nil
+}
+
+// completeRecord 设置访问密码，未指定短代码时自动生成，taken中的短代码同样视为已被使用
+func (h *APIHTTPHandler) completeRecord(request APIRequest, record *storage.URLRecord, taken map[string]bool) *APIError {
+	if err := record.SetPassword(request.Password); err != nil {
+		return newAPIError(http.StatusInternalServerError, ErrorCodeInternal, "设置访问密码失败")
+	}
+
+	// 如果短代码为空，自动生成短代码
+	if record.ShortCode == "" {
+		code, err := generateCode(h.codeGen, h.codePolicy, h.cfg.CodeLength, h.urlStorage, record.Domain, taken)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, ErrorCodeInternal, "生成短代码失败")
+		}
+		record.ShortCode = code
+	}
+	return nil
+}
+
+// findReusable 查找可以复用的短链接，要求目标地址和跳转选项都相同且没有访问限制
+// 指定了短代码、别名、访问密码或次数限制的请求不复用，避免返回与预期不符的链接
+func (h *APIHTTPHandler) findReusable(request APIRequest, record storage.URLRecord) *storage.URLRecord {
+	if request.ShortCode != "" || len(request.Aliases) > 0 || request.Password != "" || request.MaxClicks > 0 {
+		return nil
+	}
+
+	for _, existing := range h.urlStorage.FindByTarget(record.Domain, record.TargetURL) {
+		if !isProtected(&existing) && !existing.IsQuarantined() && existing.SameBehavior(record) {
+			return &existing
+		}
+	}
+	return nil
+}
+
+// writeAPIResponse 写入JSON格式的API响应
+func writeAPIResponse(w http.ResponseWriter, response APIResponse) {
+	writeJSON(w, http.StatusOK, response)
+}
+
+// writeJSON 写入JSON响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 
 	// 写入JSON响应
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
 		http.Error(w, "编码响应失败", http.StatusInternalServerError)
-		return
+	}
+}
+
+// newAPIResponse 根据已保存的记录生成API响应
+func newAPIResponse(r *http.Request, record storage.URLRecord) APIResponse {
+	shortURL := shortURLFor(r, record.Domain, record.ShortCode)
+
+	return APIResponse{
+		Domain:    record.Domain,
+		ShortCode: record.ShortCode,
+		TargetURL: record.TargetURL,
+		ShortURL:  shortURL,
+		QRCodeURL: shortURL + ".png",
+		Remark:    record.Remark,
+
+		Aliases: record.Aliases,
+
+		PassQuery:     record.PassQuery,
+		QueryConflict: record.QueryConflict,
+		PrefixMatch:   record.PrefixMatch,
+
+		PasswordProtected: record.HasPassword(),
+
+		MaxClicks:    record.MaxClicks,
+		ExhaustedURL: record.ExhaustedURL,
+
+		Interstitial: record.Interstitial,
+
+		DeviceRules:   record.DeviceRules,
+		LanguageRules: record.LanguageRules,
+
+		Variants:      record.Variants,
+		StickyVariant: record.StickyVariant,
+
+		OGTitle:       record.OGTitle,
+		OGDescription: record.OGDescription,
+		OGImage:       record.OGImage,
 	}
 }
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yu1ec/go-shorten/internal/idempotency"
+)
+
+// TestIdempotencyReleasedOnPanic 处理请求时panic后，同一幂等键可以重新处理，而不是一直返回处理中
+func TestIdempotencyReleasedOnPanic(t *testing.T) {
+	h := &APIHTTPHandler{idempotency: idempotency.NewStore(time.Hour)}
+	body := []byte(`{"target_url":"https://example.com/"}`)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("应传递处理函数的panic")
+			}
+		}()
+		h.serveIdempotent(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, ShortenPath, nil), "key", body,
+			func(http.ResponseWriter, *http.Request, []byte) { panic("boom") })
+	}()
+
+	w := httptest.NewRecorder()
+	h.serveIdempotent(w, httptest.NewRequest(http.MethodPost, ShortenPath, nil), "key", body,
+		func(w http.ResponseWriter, r *http.Request, body []byte) {
+			writeJSON(w, http.StatusOK, map[string]string{})
+		})
+	if w.Code != http.StatusOK {
+		t.Fatalf("panic后重试应重新处理并返回200，实际为%d: %s", w.Code, w.Body.String())
+	}
+}
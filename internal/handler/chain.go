@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yu1ec/go-shorten/internal/config"
+	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/urlcheck"
+)
+
+// maxChainDepth 跳转链允许经过的本站短链接数量上限
+const maxChainDepth = 10
+
+// chainResolver 解析指向本站短链接的跳转链
+type chainResolver struct {
+	urlStorage *storage.URLStorage
+	hosts      map[string]bool    // 本站的所有域名
+	domains    map[string]bool    // 拥有独立命名空间的域名
+	pending    *storage.URLRecord // 正在保存的记录，尚未写入存储
+	replaced   string             // 被正在保存的记录取代的存储记录，“域名/短码”
+}
+
+// newChainResolver 以已配置的域名和当前请求的域名作为本站域名
+func newChainResolver(urlStorage *storage.URLStorage, cfg *config.Config, r *http.Request) *chainResolver {
+	c := &chainResolver{
+		urlStorage: urlStorage,
+		hosts:      map[string]bool{storage.NormalizeDomain(r.Host): true},
+		domains:    make(map[string]bool),
+	}
+	for _, host := range cfg.DomainHosts() {
+		c.hosts[host] = true
+		c.domains[host] = true
+	}
+	return c
+}
+
+// chainResult 跳转链的解析结果
+type chainResult struct {
+	depth     int    // 经过的本站短链接数量
+	final     string // 跳转链最终到达的地址
+	flattened string // 跳过中间普通短链接后的地址
+}
+
+// resolve 从target开始沿本站短链接的目标地址逐层解析，domain和code为正在保存的记录，
+// 跳转链回到该记录或其他已经过的短链接时返回错误
+func (c *chainResolver) resolve(domain, code, target string) (chainResult, error) {
+	visited := make(map[string]bool)
+	if code != "" {
+		visited[domain+"/"+code] = true
+	}
+
+	result := chainResult{final: target, flattened: target}
+	flattenable := true
+	for {
+		record, suffix, query, ok := c.hop(result.final)
+		if !ok {
+			return result, nil
+		}
+
+		key := record.Domain + "/" + record.ShortCode
+		if visited[key] {
+			return result, errors.New("跳转链存在循环，最终会回到自身")
+		}
+		visited[key] = true
+
+		result.depth++
+		if result.depth > maxChainDepth {
+			return result, fmt.Errorf("跳转链超过%d层", maxChainDepth)
+		}
+
+		next := record.TargetURL + suffix
+		if query != "" {
+			next += "?" + query
+		}
+
+		// 只跳过没有额外访问逻辑的短链接，避免绕过密码、次数限制和定向规则
+		if flattenable && suffix == "" && query == "" && isPlainRedirect(record) {
+			result.flattened = next
+		} else {
+			flattenable = false
+		}
+		result.final = next
+	}
+}
+
+// hop 判断地址是否指向本站短链接，返回对应记录、前缀模式下的路径后缀和查询参数
+func (c *chainResolver) hop(target string) (*storage.URLRecord, string, string, bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, "", "", false
+	}
+
+	host := storage.NormalizeDomain(u.Host)
+	if !c.hosts[host] {
+		return nil, "", "", false
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	if path == "" || strings.HasSuffix(path, "+") {
+		return nil, "", "", false
+	}
+
+	domain := ""
+	if c.domains[host] {
+		domain = host
+	}
+
+	record, suffix, err := c.lookup(domain, path)
+	if err != nil {
+		return nil, "", "", false
+	}
+	return record, suffix, u.RawQuery, true
+}
+
+// lookup 查找短链接，正在保存的记录优先于存储中的记录
+func (c *chainResolver) lookup(domain, path string) (*storage.URLRecord, string, error) {
+	if p := c.pending; p != nil && p.ShortCode != "" {
+		code, rest, found := strings.Cut(path, "/")
+		matched := c.urlStorage.HasName(*p, code) && (!found || p.PrefixMatch)
+		// 默认域名的记录只在访问域名下没有同名短码时命中
+		if matched && (p.Domain == domain || (p.Domain == "" && !c.exists(domain, code))) {
+			if !found {
+				return p, "", nil
+			}
+			return p, "/" + rest, nil
+		}
+	}
+	record, suffix, err := lookupRecord(c.urlStorage, domain, path)
+	if err == nil && c.replaced != "" && record.Domain+"/"+record.ShortCode == c.replaced {
+		return nil, "", storage.ErrNotFound
+	}
+	return record, suffix, err
+}
+
+// exists 判断域名命名空间中是否存在该短码
+func (c *chainResolver) exists(domain, code string) bool {
+	_, err := c.urlStorage.GetURL(domain, code)
+	return err == nil
+}
+
+// isPlainRedirect 判断短链接是否只是直接跳转到TargetURL
+func isPlainRedirect(record *storage.URLRecord) bool {
+	return !isProtected(record) && !record.Interstitial && !record.PassQuery && !record.PrefixMatch &&
+		len(record.DeviceRules) == 0 && len(record.LanguageRules) == 0 && len(record.Variants) == 0
+}
+
+// checkRecordChains 检查记录中所有地址的跳转链，flatten为true时将地址替换为跳过中间短链接后的地址
+func (c *chainResolver) checkRecordChains(record *storage.URLRecord, flatten bool) urlcheck.Errors {
+	c.pending = record
+	defer func() { c.pending = nil }()
+
+	var errs urlcheck.Errors
+	forEachURLField(record, func(field string, value *string, optional bool) {
+		if *value == "" || field == "og_image" {
+			return
+		}
+		result, err := c.resolve(record.Domain, record.ShortCode, *value)
+		if err != nil {
+			errs = append(errs, urlcheck.FieldError{Field: field, Message: err.Error()})
+			return
+		}
+		if flatten {
+			*value = result.flattened
+		}
+	})
+	return errs
+}
+
+// checkRename 检查修改短代码后的记录，以及跳转链经过该记录的其他短链接是否会出现循环或超过层数上限，
+// 修改前已存在问题的跳转链不计入
+func (c *chainResolver) checkRename(existing storage.URLRecord, renamed *storage.URLRecord) (urlcheck.Errors, error) {
+	records, err := c.urlStorage.GetAllURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	c.replaced = existing.Domain + "/" + existing.ShortCode
+	defer func() { c.replaced = "" }()
+
+	var errs urlcheck.Errors
+	for _, fieldErr := range c.checkRecordChains(renamed, false) {
+		errs = append(errs, urlcheck.FieldError{Field: "new_code", Message: fieldErr.Message})
+	}
+
+	for _, record := range records {
+		if record.Domain+"/"+record.ShortCode == c.replaced {
+			continue
+		}
+		forEachURLField(&record, func(field string, value *string, optional bool) {
+			if *value == "" || field == "og_image" {
+				return
+			}
+			if _, err := c.resolveWithout(record.Domain, record.ShortCode, *value); err != nil {
+				return
+			}
+
+			c.pending = renamed
+			_, err := c.resolve(record.Domain, record.ShortCode, *value)
+			c.pending = nil
+			if err != nil {
+				errs = append(errs, urlcheck.FieldError{
+					Field:   "new_code",
+					Message: fmt.Sprintf("短链接 %s 的跳转链将出现问题：%v", displayName(record), err),
+				})
+			}
+		})
+	}
+	return errs, nil
+}
+
+// resolveWithout 按存储中的现有记录解析跳转链，忽略正在保存的记录
+func (c *chainResolver) resolveWithout(domain, code, target string) (chainResult, error) {
+	replaced := c.replaced
+	c.replaced = ""
+	defer func() { c.replaced = replaced }()
+	return c.resolve(domain, code, target)
+}
+
+// displayName 返回管理界面中展示的短链接名称
+func displayName(record storage.URLRecord) string {
+	if record.Domain == "" {
+		return record.ShortCode
+	}
+	return record.Domain + "/" + record.ShortCode
+}
+
+// chainDepths 计算每条记录目标地址的跳转链深度，键为“域名/短码”
+func (c *chainResolver) chainDepths(records []storage.URLRecord) map[string]int {
+	depths := make(map[string]int)
+	for _, record := range records {
+		result, err := c.resolve(record.Domain, record.ShortCode, record.TargetURL)
+		if err != nil {
+			// 存在循环时记为-1，在管理界面中提示
+			depths[record.Domain+"/"+record.ShortCode] = -1
+			continue
+		}
+		if result.depth > 0 {
+			depths[record.Domain+"/"+record.ShortCode] = result.depth
+		}
+	}
+	return depths
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yu1ec/go-shorten/internal/auth"
+	"github.com/yu1ec/go-shorten/internal/idempotency"
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// errorMessage 将存储层、用户管理和幂等键的错误转换为面向用户的提示信息，其他错误原样返回
+func errorMessage(err error) string {
+	var se *storage.Error
+	if errors.As(err, &se) {
+		return storageErrorMessage(se)
+	}
+	var ae *auth.Error
+	if errors.As(err, &ae) {
+		return authErrorMessage(ae)
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return "链接不存在"
+	case errors.Is(err, storage.ErrConflict):
+		return "短链接代码已存在"
+	case errors.Is(err, storage.ErrBatchAborted):
+		return "批量中其他链接创建失败，本链接未创建"
+	case errors.Is(err, storage.ErrClicksExhausted):
+		return "链接访问次数已用完"
+	case errors.Is(err, idempotency.ErrMismatch):
+		return "Idempotency-Key已用于内容不同的请求"
+	case errors.Is(err, idempotency.ErrInProgress):
+		return "使用该Idempotency-Key的请求正在处理中，请稍后重试"
+	case errors.Is(err, auth.ErrNotFound):
+		return "用户不存在"
+	case errors.Is(err, auth.ErrConflict):
+		return "用户已存在"
+	case errors.Is(err, auth.ErrInvalid):
+		return "用户名或密码不能为空"
+	}
+	return err.Error()
+}
+
+// storageErrorMessage 根据存储层错误的类型和字段生成提示信息
+func storageErrorMessage(e *storage.Error) string {
+	switch {
+	case e.Field == storage.FieldVariant && errors.Is(e, storage.ErrNotFound):
+		return fmt.Sprintf("版本 %s 不存在", e.Value)
+	case errors.Is(e, storage.ErrNotFound):
+		return "链接不存在"
+	case e.Field == storage.FieldAlias && errors.Is(e, storage.ErrConflict):
+		return fmt.Sprintf("别名 %s 已被使用", e.Value)
+	case errors.Is(e, storage.ErrConflict):
+		return "短链接代码已存在"
+	case e.Field == storage.FieldAlias && errors.Is(e, storage.ErrInvalid):
+		return fmt.Sprintf("别名 %s 重复", e.Value)
+	case e.Field == storage.FieldShortCode && errors.Is(e, storage.ErrInvalid):
+		return "新短代码与原短代码相同"
+	}
+	return e.Error()
+}
+
+// authErrorMessage 根据用户管理错误的类型和字段生成提示信息
+func authErrorMessage(e *auth.Error) string {
+	switch {
+	case errors.Is(e, auth.ErrNotFound):
+		return "用户不存在"
+	case errors.Is(e, auth.ErrConflict):
+		return "用户已存在"
+	case e.Field == auth.FieldUsername && errors.Is(e, auth.ErrInvalid):
+		return "用户名不能为空"
+	case e.Field == auth.FieldPassword && errors.Is(e, auth.ErrInvalid):
+		return "密码不能为空"
+	}
+	return e.Error()
+}
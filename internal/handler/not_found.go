@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/yu1ec/go-shorten/internal/config"
+)
+
+// handleRoot 处理根路径：依次尝试域名兜底地址、首页地址、域名404页面，最后展示链接不存在页面
+func (h *RedirectHTTPHandler) handleRoot(w http.ResponseWriter, r *http.Request, domainCfg *config.Domain) {
+	if domainCfg != nil && domainCfg.FallbackURL != "" {
+		http.Redirect(w, r, domainCfg.FallbackURL, http.StatusFound)
+		return
+	}
+	if h.cfg.HomeURL != "" {
+		http.Redirect(w, r, h.cfg.HomeURL, http.StatusFound)
+		return
+	}
+	if domainCfg != nil && len(domainCfg.NotFoundHTML) > 0 {
+		writeNotFoundHTML(w, domainCfg.NotFoundHTML)
+		return
+	}
+
+	h.renderTemplate(w, "not_found.html", map[string]interface{}{
+		"title":   "链接不存在",
+		"message": "请检查短链接地址是否正确",
+		"homeURL": h.cfg.HomeURL,
+	}, http.StatusNotFound)
+}
+
+// handleNotFound 处理未知短代码：已删除的返回410，否则依次尝试域名兜底地址、域名404页面、
+// 全局兜底地址，最后展示带相似短码推荐的链接不存在页面
+func (h *RedirectHTTPHandler) handleNotFound(w http.ResponseWriter, r *http.Request, domainCfg *config.Domain, path string) {
+	domain := ""
+	if domainCfg != nil {
+		domain = domainCfg.Host
+	}
+
+	if h.urlStorage.IsDeleted(domain, path) || (domain != "" && h.urlStorage.IsDeleted("", path)) {
+		h.renderTemplate(w, "not_found.html", map[string]interface{}{
+			"title":   "链接已删除",
+			"message": "该短链接已被删除，无法继续访问",
+			"homeURL": h.cfg.HomeURL,
+		}, http.StatusGone)
+		return
+	}
+
+	if domainCfg != nil {
+		if domainCfg.FallbackURL != "" {
+			http.Redirect(w, r, domainCfg.FallbackURL, http.StatusFound)
+			return
+		}
+		if len(domainCfg.NotFoundHTML) > 0 {
+			writeNotFoundHTML(w, domainCfg.NotFoundHTML)
+			return
+		}
+	}
+	if h.cfg.NotFoundURL != "" {
+		http.Redirect(w, r, h.cfg.NotFoundURL, http.StatusFound)
+		return
+	}
+
+	h.renderTemplate(w, "not_found.html", map[string]interface{}{
+		"title":       "链接不存在",
+		"message":     "请检查短链接地址是否正确",
+		"shortCode":   path,
+		"suggestions": h.similarCodes(domain, path, h.cfg.NotFoundSuggestions),
+		"homeURL":     h.cfg.HomeURL,
+	}, http.StatusNotFound)
+}
+
+// writeNotFoundHTML 以404状态返回域名配置的自定义页面
+func writeNotFoundHTML(w http.ResponseWriter, html []byte) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(html)
+}
+
+// similarCodes 按编辑距离查找与访问路径相近的短码，不推荐受保护的链接
+func (h *RedirectHTTPHandler) similarCodes(domain, path string, limit int) []string {
+	if limit <= 0 {
+		return nil
+	}
+
+	// 短码较短时只允许一处差异，避免推荐不相关的短码
+	length := len([]rune(path))
+	maxDistance := 2
+	if length < 4 {
+		maxDistance = 1
+	}
+
+	// 编辑距离不超过maxDistance的短码长度差也不超过maxDistance，只比较长度相近的全部记录，
+	// 按距离排序后再截取，保证同一路径每次得到相同的推荐
+	records := h.urlStorage.FindByCodeLength(domain, length-maxDistance, length+maxDistance)
+	if domain != "" {
+		records = append(records, h.urlStorage.FindByCodeLength("", length-maxDistance, length+maxDistance)...)
+	}
+
+	type candidate struct {
+		code     string
+		distance int
+	}
+	var candidates []candidate
+	seen := make(map[string]bool)
+	for _, record := range records {
+		// 只推荐当前域名及默认域名下可以直接访问的短码
+		if record.Domain != domain && record.Domain != "" {
+			continue
+		}
+		if isProtected(&record) || seen[record.ShortCode] {
+			continue
+		}
+
+		d := editDistance(strings.ToLower(path), strings.ToLower(record.ShortCode))
+		if d <= maxDistance {
+			seen[record.ShortCode] = true
+			candidates = append(candidates, candidate{code: record.ShortCode, distance: d})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].code < candidates[j].code
+	})
+
+	var codes []string
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		codes = append(codes, candidates[i].code)
+	}
+	return codes
+}
+
+// editDistance 计算两个字符串之间的Levenshtein编辑距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
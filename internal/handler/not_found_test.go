@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// TestSimilarCodesScansAllRecords 记录很多时仍能找到编辑距离最近的短码，且每次推荐结果相同
+func TestSimilarCodesScansAllRecords(t *testing.T) {
+	h, urlStorage := newTestRedirectHandler(t)
+
+	var records []storage.URLRecord
+	for i := 0; i < 3000; i++ {
+		records = append(records, storage.URLRecord{ShortCode: fmt.Sprintf("x%05d", i), TargetURL: "https://example.com/"})
+	}
+	records = append(records, storage.URLRecord{ShortCode: "promo1", TargetURL: "https://example.com/promo"})
+	for _, err := range urlStorage.CreateURLs(records, true) {
+		if err != nil {
+			t.Fatalf("创建短链接失败: %v", err)
+		}
+	}
+
+	first := h.similarCodes("", "prom01", 3)
+	if len(first) == 0 || first[0] != "promo1" {
+		t.Fatalf("最相近的短码应为promo1，实际为%v", first)
+	}
+	for i := 0; i < 10; i++ {
+		if got := h.similarCodes("", "prom01", 3); !slices.Equal(got, first) {
+			t.Fatalf("推荐结果应保持不变，第一次为%v，之后为%v", first, got)
+		}
+	}
+}
@@ -1,40 +1,453 @@
 package handler
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/yu1ec/go-shorten/internal/config"
+	"github.com/yu1ec/go-shorten/internal/qrcode"
+	"github.com/yu1ec/go-shorten/internal/ratelimit"
 	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/useragent"
 )
 
 // RedirectHTTPHandler 处理重定向
 type RedirectHTTPHandler struct {
-	urlStorage *storage.URLStorage
+	urlStorage    *storage.URLStorage
+	cfg           *config.Config
+	templates     map[string]*template.Template
+	unlockLimiter *ratelimit.Limiter
 }
 
 // NewRedirectHTTPHandler 创建重定向处理器
-func NewRedirectHTTPHandler(urlStorage *storage.URLStorage) *RedirectHTTPHandler {
+func NewRedirectHTTPHandler(urlStorage *storage.URLStorage, cfg *config.Config) *RedirectHTTPHandler {
+	unlockLimiter := ratelimit.NewLimiter(cfg.UnlockMaxAttempts, cfg.UnlockAttemptWindow)
+	unlockLimiter.StartGCTimer()
+
 	return &RedirectHTTPHandler{
-		urlStorage: urlStorage,
+		urlStorage:    urlStorage,
+		cfg:           cfg,
+		templates:     parseStandaloneTemplates("unlock.html", "preview.html", "opengraph.html", "not_found.html", "quarantine.html"),
+		unlockLimiter: unlockLimiter,
 	}
 }
 
 // ServeHTTP 实现http.Handler接口
 func (h *RedirectHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 获取短代码
-	shortCode := strings.TrimPrefix(r.URL.Path, "/")
-	if shortCode == "" {
-		http.NotFound(w, r)
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	// 预览模式：/{code}+ 或 ?preview
+	query := r.URL.Query()
+	preview := strings.HasSuffix(path, "+") || query.Has("preview")
+	path = strings.TrimSuffix(path, "+")
+	query.Del("preview")
+
+	// 按访问域名确定命名空间，未配置的域名使用默认命名空间
+	domainCfg := h.cfg.Domain(r.Host)
+	domain := ""
+	if domainCfg != nil {
+		domain = domainCfg.Host
+	}
+
+	if path == "" {
+		h.handleRoot(w, r, domainCfg)
 		return
 	}
 
+	// 二维码：/{code}.png 或 /{code}.svg，短代码本身带扩展名时优先按短代码处理
+	if code, format, ok := parseQRCodePath(path); ok && !preview {
+		if _, err := getRecord(h.urlStorage, domain, path); err != nil {
+			h.handleQRCode(w, r, domain, code, format)
+			return
+		}
+	}
+
 	// 查找URL
-	url, err := h.urlStorage.GetURLByCode(shortCode)
+	record, suffix, err := lookupRecord(h.urlStorage, domain, path)
 	if err != nil {
-		http.NotFound(w, r)
+		h.handleNotFound(w, r, domainCfg, path)
+		return
+	}
+
+	// 已隔离的链接只展示警告页，不透露目标地址
+	if record.IsQuarantined() {
+		h.renderTemplate(w, "quarantine.html", map[string]interface{}{
+			"title": "链接已被拦截",
+		}, http.StatusForbidden)
+		return
+	}
+
+	// 访问次数已用完时无需再校验密码
+	if record.IsExhausted() {
+		h.handleExhausted(w, r, record)
+		return
+	}
+
+	// 社交平台爬虫返回链接预览页，不消耗访问次数
+	if useragent.IsCrawler(r.UserAgent()) && (record.HasOpenGraph() || isProtected(record)) {
+		h.renderOpenGraph(w, r, record, suffix, query)
+		return
+	}
+
+	// 密码保护
+	if record.HasPassword() && !h.handleUnlock(w, r, record) {
+		return
+	}
+
+	// 拼接路径后缀和查询参数
+	base, variant := h.selectTarget(w, r, record)
+	target, err := buildRedirectURL(base, record, suffix, query)
+	if err != nil {
+		http.Error(w, "目标URL无效", http.StatusInternalServerError)
+		return
+	}
+
+	// 预览不消耗访问次数
+	if preview {
+		h.renderPreview(w, record, target, 0)
+		return
+	}
+
+	// HEAD请求多来自链接扫描、聊天软件预览和健康检查，只按记录返回结果，不消耗访问次数也不统计版本
+	if r.Method != http.MethodHead {
+		// 消耗一次访问次数
+		if err := h.urlStorage.ConsumeClick(record.Domain, record.ShortCode); err != nil {
+			if errors.Is(err, storage.ErrClicksExhausted) {
+				h.handleExhausted(w, r, record)
+				return
+			}
+			// 计数持久化失败不影响本次跳转
+			log.Printf("记录访问次数失败: %s: %v\n", record.ShortCode, err)
+		}
+
+		// 记录A/B版本
+		if variant != "" {
+			h.recordVariant(w, record, variant)
+		}
+	}
+
+	// 跳转提示页
+	if record.Interstitial {
+		h.renderPreview(w, record, target, h.cfg.InterstitialSeconds)
 		return
 	}
 
 	// 执行重定向
-	http.Redirect(w, r, url.TargetURL, http.StatusFound)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// isProtected 链接是否设置了密码或访问次数限制，这类链接不向爬虫暴露目标地址
+func isProtected(record *storage.URLRecord) bool {
+	return record.HasPassword() || record.MaxClicks > 0
+}
+
+// renderOpenGraph 为社交平台爬虫渲染带 Open Graph / Twitter Card 标签的页面
+// 受保护的链接不包含目标地址，其余链接附带 meta refresh，被误判为爬虫的访问者仍能到达目标地址
+func (h *RedirectHTTPHandler) renderOpenGraph(w http.ResponseWriter, r *http.Request, record *storage.URLRecord, suffix string, query url.Values) {
+	title := record.OGTitle
+	if title == "" {
+		title = record.Remark
+	}
+	if title == "" {
+		title = record.ShortCode
+	}
+
+	data := map[string]interface{}{
+		"title":       title,
+		"description": record.OGDescription,
+		"image":       record.OGImage,
+		"shortURL":    shortURLFor(r, record.Domain, record.ShortCode),
+	}
+	if !isProtected(record) {
+		if target, err := buildRedirectURL(record.TargetURL, record, suffix, query); err == nil {
+			data["targetURL"] = target
+		}
+	}
+
+	w.Header().Add("Vary", "User-Agent")
+	h.renderTemplate(w, "opengraph.html", data, http.StatusOK)
+}
+
+// parseQRCodePath 解析二维码请求路径，返回短代码和图片格式
+func parseQRCodePath(path string) (string, string, bool) {
+	for _, format := range []string{"png", "svg"} {
+		if code, found := strings.CutSuffix(path, "."+format); found && code != "" {
+			return code, format, true
+		}
+	}
+	return "", "", false
+}
+
+// handleQRCode 生成短链接的二维码图片
+// 支持参数：size 图片边长（像素），margin 空白边距（模块数），level 纠错等级 L/M/Q/H
+func (h *RedirectHTTPHandler) handleQRCode(w http.ResponseWriter, r *http.Request, domain, code, format string) {
+	record, err := getRecord(h.urlStorage, domain, code)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	size, err := queryInt(query, "size", 256, 32, 2048)
+	if err != nil {
+		http.Error(w, "size参数无效", http.StatusBadRequest)
+		return
+	}
+	margin, err := queryInt(query, "margin", 4, 0, 16)
+	if err != nil {
+		http.Error(w, "margin参数无效", http.StatusBadRequest)
+		return
+	}
+	level, err := qrcode.ParseLevel(query.Get("level"))
+	if err != nil {
+		http.Error(w, "level参数无效", http.StatusBadRequest)
+		return
+	}
+
+	qr, err := qrcode.Encode(shortURLFor(r, record.Domain, record.ShortCode), level)
+	if err != nil {
+		http.Error(w, "生成二维码失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		io.WriteString(w, qr.SVG(size, margin))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := qr.WritePNG(&buf, size, margin); err != nil {
+		http.Error(w, "生成二维码失败", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// queryInt 读取整数查询参数，未提供时返回默认值，超出范围时返回错误
+func queryInt(query url.Values, key string, defaultValue, minValue, maxValue int) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < minValue || n > maxValue {
+		return 0, fmt.Errorf("%s超出范围[%d, %d]", key, minValue, maxValue)
+	}
+	return n, nil
+}
+
+// renderPreview 渲染预览页，countdown大于0时作为跳转提示页在倒计时结束后自动跳转
+func (h *RedirectHTTPHandler) renderPreview(w http.ResponseWriter, record *storage.URLRecord, target string, countdown int) {
+	title := "链接预览"
+	if countdown > 0 {
+		title = "即将离开本站"
+	}
+
+	h.renderTemplate(w, "preview.html", map[string]interface{}{
+		"title":     title,
+		"shortCode": record.ShortCode,
+		"targetURL": target,
+		"record":    record,
+		"countdown": countdown,
+		// 限制次数的链接在预览时隐藏目标地址，避免绕过次数限制
+		"hideTarget": countdown == 0 && record.MaxClicks > 0,
+	}, http.StatusOK)
+}
+
+// handleExhausted 访问次数用完后跳转到备用地址，未设置时返回410
+func (h *RedirectHTTPHandler) handleExhausted(w http.ResponseWriter, r *http.Request, record *storage.URLRecord) {
+	if record.ExhaustedURL != "" {
+		http.Redirect(w, r, record.ExhaustedURL, http.StatusFound)
+		return
+	}
+	http.Error(w, "链接已失效", http.StatusGone)
+}
+
+// renderTemplate 渲染独立模板
+func (h *RedirectHTTPHandler) renderTemplate(w http.ResponseWriter, name string, data map[string]interface{}, status int) {
+	tmpl, exists := h.templates[name]
+	if !exists {
+		http.Error(w, "模板不存在", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("渲染模板 %s 失败: %v\n", name, err)
+	}
+}
+
+// lookupRecord 根据请求路径查找记录，返回记录以及前缀模式下剩余的路径后缀
+func lookupRecord(urlStorage *storage.URLStorage, domain, path string) (*storage.URLRecord, string, error) {
+	// 短代码不包含 /，不带 / 的路径精确匹配
+	code, rest, found := strings.Cut(path, "/")
+	if !found {
+		record, err := getRecord(urlStorage, domain, path)
+		if err != nil {
+			return nil, "", err
+		}
+		return record, "", nil
+	}
+
+	// 前缀模式：第一段为短代码，其余部分作为路径后缀
+	record, err := getRecord(urlStorage, domain, code)
+	if err != nil {
+		return nil, "", err
+	}
+	if !record.PrefixMatch {
+		return nil, "", storage.ErrNotFound
+	}
+
+	return record, "/" + rest, nil
+}
+
+// getRecord 先在访问域名的命名空间中查找，未找到时回退到默认命名空间
+func getRecord(urlStorage *storage.URLStorage, domain, code string) (*storage.URLRecord, error) {
+	record, err := urlStorage.GetURL(domain, code)
+	if err != nil && domain != "" {
+		return urlStorage.GetURL("", code)
+	}
+	return record, err
+}
+
+// selectTarget 根据访问者选择跳转的基础地址，命中A/B分流时同时返回版本名称，规则优先级：
+//  1. 设备平台规则（按配置顺序，第一条命中的生效）
+//  2. 语言规则（按 Accept-Language 偏好）
+//  3. A/B 分流（按权重随机，开启固定版本时优先使用Cookie中记录的版本）
+//  4. 默认目标地址 TargetURL
+func (h *RedirectHTTPHandler) selectTarget(w http.ResponseWriter, r *http.Request, record *storage.URLRecord) (string, string) {
+	if len(record.DeviceRules) > 0 {
+		w.Header().Add("Vary", "User-Agent")
+		platforms := useragent.Platforms(r.UserAgent())
+		for _, rule := range record.DeviceRules {
+			if slices.Contains(platforms, rule.Platform) {
+				return rule.TargetURL, ""
+			}
+		}
+	}
+
+	if len(record.LanguageRules) > 0 {
+		w.Header().Add("Vary", "Accept-Language")
+		if target, ok := matchLanguageRule(r.Header.Get("Accept-Language"), record.LanguageRules); ok {
+			return target, ""
+		}
+	}
+
+	if variant, ok := pickVariant(r, record); ok {
+		return variant.TargetURL, variant.Name
+	}
+
+	return record.TargetURL, ""
+}
+
+// 固定A/B版本的Cookie名前缀及有效期
+const (
+	variantCookiePrefix = "go-shorten-variant-"
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// pickVariant 选择A/B版本，所有版本权重均为0时返回false
+func pickVariant(r *http.Request, record *storage.URLRecord) (storage.Variant, bool) {
+	if record.StickyVariant {
+		if cookie, err := r.Cookie(variantCookiePrefix + record.ShortCode); err == nil {
+			for _, v := range record.Variants {
+				if v.Name == cookie.Value && v.Weight > 0 {
+					return v, true
+				}
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range record.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return storage.Variant{}, false
+	}
+
+	n := rand.IntN(total)
+	for _, v := range record.Variants {
+		if n < v.Weight {
+			return v, true
+		}
+		n -= v.Weight
+	}
+	return storage.Variant{}, false
+}
+
+// recordVariant 记录本次访问的A/B版本，开启固定版本时写入Cookie
+func (h *RedirectHTTPHandler) recordVariant(w http.ResponseWriter, record *storage.URLRecord, variant string) {
+	if record.StickyVariant {
+		http.SetCookie(w, &http.Cookie{
+			Name:     variantCookiePrefix + record.ShortCode,
+			Value:    variant,
+			Path:     "/",
+			MaxAge:   int(variantCookieMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	if err := h.urlStorage.RecordVariantHit(record.Domain, record.ShortCode, variant); err != nil {
+		log.Printf("记录A/B版本失败: %s: %v\n", record.ShortCode, err)
+	}
+}
+
+// buildRedirectURL 根据记录的跳转选项，在基础地址上拼接路径后缀和查询参数
+func buildRedirectURL(base string, record *storage.URLRecord, suffix string, query url.Values) (string, error) {
+	if suffix == "" && (!record.PassQuery || len(query) == 0) {
+		return base, nil
+	}
+
+	target, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	// 拼接路径后缀
+	if suffix != "" {
+		target.Path = strings.TrimSuffix(target.Path, "/") + suffix
+		target.RawPath = ""
+	}
+
+	// 合并查询参数
+	if record.PassQuery && len(query) > 0 {
+		targetQuery := target.Query()
+		for key, values := range query {
+			switch record.QueryConflict {
+			case storage.QueryConflictOverride:
+				targetQuery[key] = values
+			case storage.QueryConflictAppend:
+				targetQuery[key] = append(targetQuery[key], values...)
+			default:
+				if _, exists := targetQuery[key]; !exists {
+					targetQuery[key] = values
+				}
+			}
+		}
+		target.RawQuery = targetQuery.Encode()
+	}
+
+	return target.String(), nil
 }
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/yu1ec/go-shorten/internal/config"
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// newTestRedirectHandler 在临时目录中创建存储和跳转处理器，并写入给定的记录
+func newTestRedirectHandler(t *testing.T, records ...storage.URLRecord) (*RedirectHTTPHandler, *storage.URLStorage) {
+	t.Helper()
+
+	// 数据文件写入当前目录下的data目录
+	t.Chdir(t.TempDir())
+
+	urlStorage, err := storage.NewURLStorage(false)
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	for _, record := range records {
+		if err := urlStorage.CreateURL(record); err != nil {
+			t.Fatalf("创建短链接失败: %v", err)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	return NewRedirectHTTPHandler(urlStorage, cfg), urlStorage
+}
+
+func serveRedirect(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+// TestHeadDoesNotConsumeClicks HEAD请求不消耗访问次数，之后的GET请求仍能跳转
+func TestHeadDoesNotConsumeClicks(t *testing.T) {
+	h, urlStorage := newTestRedirectHandler(t, storage.URLRecord{
+		ShortCode: "secret",
+		TargetURL: "https://example.com/handoff",
+		MaxClicks: 1,
+	})
+
+	for i := 0; i < 3; i++ {
+		w := serveRedirect(h, http.MethodHead, "/secret")
+		if w.Code != http.StatusFound {
+			t.Fatalf("HEAD请求应返回302，实际为%d", w.Code)
+		}
+	}
+
+	record, err := urlStorage.GetURL("", "secret")
+	if err != nil {
+		t.Fatalf("读取短链接失败: %v", err)
+	}
+	if record.ClickCount != 0 {
+		t.Errorf("HEAD请求后访问次数应为0，实际为%d", record.ClickCount)
+	}
+
+	w := serveRedirect(h, http.MethodGet, "/secret")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/handoff" {
+		t.Fatalf("GET请求应跳转到目标地址，实际为%d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if w := serveRedirect(h, method, "/secret"); w.Code != http.StatusGone {
+			t.Errorf("次数用完后%s请求应返回410，实际为%d", method, w.Code)
+		}
+	}
+}
+
+// TestConcurrentClicksRespectLimit 并发访问时成功跳转的次数不超过限制
+func TestConcurrentClicksRespectLimit(t *testing.T) {
+	const maxClicks = 5
+	const requests = 50
+
+	h, urlStorage := newTestRedirectHandler(t, storage.URLRecord{
+		ShortCode: "limited",
+		TargetURL: "https://example.com/",
+		MaxClicks: maxClicks,
+	})
+
+	var mutex sync.Mutex
+	codes := make(map[int]int)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serveRedirect(h, http.MethodGet, "/limited")
+			mutex.Lock()
+			codes[w.Code]++
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if codes[http.StatusFound] != maxClicks || codes[http.StatusGone] != requests-maxClicks {
+		t.Errorf("应有%d次跳转、%d次410，实际为%v", maxClicks, requests-maxClicks, codes)
+	}
+
+	record, err := urlStorage.GetURL("", "limited")
+	if err != nil {
+		t.Fatalf("读取短链接失败: %v", err)
+	}
+	if record.ClickCount != maxClicks {
+		t.Errorf("访问次数应为%d，实际为%d", maxClicks, record.ClickCount)
+	}
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/useragent"
+)
+
+// parseRuleLines 解析管理表单中按行填写的规则，每行格式为“键 目标URL”，忽略空行和#开头的注释
+// keyName 为键的名称，用于错误提示
+func parseRuleLines(text, keyName string) ([][2]string, error) {
+	var result [][2]string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("第%d行格式错误，应为“%s 目标URL”", i+1, keyName)
+		}
+		result = append(result, [2]string{fields[0], fields[1]})
+	}
+	return result, nil
+}
+
+// parseDeviceRules 解析设备平台规则文本
+func parseDeviceRules(text string) ([]storage.DeviceRule, error) {
+	lines, err := parseRuleLines(text, "平台")
+	if err != nil {
+		return nil, fmt.Errorf("设备规则%s", err.Error())
+	}
+
+	var rules []storage.DeviceRule
+	for _, line := range lines {
+		rules = append(rules, storage.DeviceRule{
+			Platform:  line[0],
+			TargetURL: line[1],
+		})
+	}
+	return rules, nil
+}
+
+// formatDeviceRules 将设备平台规则格式化为表单文本
+func formatDeviceRules(rules []storage.DeviceRule) string {
+	var b strings.Builder
+	for _, rule := range rules {
+		b.WriteString(rule.Platform + " " + rule.TargetURL + "\n")
+	}
+	return b.String()
+}
+
+// validateDeviceRules 将平台名称统一为小写后校验设备平台规则，返回错误提示，通过时返回空字符串
+// 管理表单和API都经过这里，平台名称不区分大小写
+func validateDeviceRules(rules []storage.DeviceRule) string {
+	for i := range rules {
+		rules[i].Platform = strings.ToLower(rules[i].Platform)
+		rule := rules[i]
+		if !useragent.IsValidPlatform(rule.Platform) {
+			return fmt.Sprintf("不支持的设备平台: %s，可选值: %s", rule.Platform, strings.Join(useragent.AllPlatforms, ", "))
+		}
+		if rule.TargetURL == "" {
+			return "设备规则的目标URL不能为空"
+		}
+	}
+	return ""
+}
+
+// 语言标签格式，如 zh、zh-CN、zh-Hant-TW
+var languageTagRegex = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// parseLanguageRules 解析语言规则文本
+func parseLanguageRules(text string) ([]storage.LanguageRule, error) {
+	lines, err := parseRuleLines(text, "语言")
+	if err != nil {
+		return nil, fmt.Errorf("语言规则%s", err.Error())
+	}
+
+	var rules []storage.LanguageRule
+	for _, line := range lines {
+		rules = append(rules, storage.LanguageRule{
+			Language:  line[0],
+			TargetURL: line[1],
+		})
+	}
+	return rules, nil
+}
+
+// formatLanguageRules 将语言规则格式化为表单文本
+func formatLanguageRules(rules []storage.LanguageRule) string {
+	var b strings.Builder
+	for _, rule := range rules {
+		b.WriteString(rule.Language + " " + rule.TargetURL + "\n")
+	}
+	return b.String()
+}
+
+// validateLanguageRules 校验语言规则，返回错误提示，通过时返回空字符串
+func validateLanguageRules(rules []storage.LanguageRule) string {
+	for _, rule := range rules {
+		if !languageTagRegex.MatchString(rule.Language) {
+			return fmt.Sprintf("无效的语言标签: %s", rule.Language)
+		}
+		if rule.TargetURL == "" {
+			return "语言规则的目标URL不能为空"
+		}
+	}
+	return ""
+}
+
+// languagePreference Accept-Language 中的一项语言偏好
+type languagePreference struct {
+	tag     string
+	quality float64
+}
+
+// parseAcceptLanguage 解析 Accept-Language 请求头，按权重从高到低排序，忽略权重为0和格式错误的项
+func parseAcceptLanguage(header string) []languagePreference {
+	var prefs []languagePreference
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			quality = q
+		}
+		if quality == 0 {
+			continue
+		}
+
+		prefs = append(prefs, languagePreference{tag: tag, quality: quality})
+	}
+
+	// 权重相同时保持请求头中的先后顺序
+	sort.SliceStable(prefs, func(i, j int) bool {
+		return prefs[i].quality > prefs[j].quality
+	})
+	return prefs
+}
+
+// matchLanguageRule 按访问者的语言偏好选择规则
+// 依次检查每个偏好语言：先精确匹配语言标签，再按主语言匹配（如 zh-TW 可匹配 zh 或 zh-CN 的规则）
+func matchLanguageRule(header string, rules []storage.LanguageRule) (string, bool) {
+	for _, pref := range parseAcceptLanguage(header) {
+		if pref.tag == "*" {
+			continue
+		}
+
+		for _, rule := range rules {
+			if strings.EqualFold(rule.Language, pref.tag) {
+				return rule.TargetURL, true
+			}
+		}
+
+		primary := primaryLanguage(pref.tag)
+		for _, rule := range rules {
+			if strings.EqualFold(primaryLanguage(rule.Language), primary) {
+				return rule.TargetURL, true
+			}
+		}
+	}
+	return "", false
+}
+
+// primaryLanguage 返回语言标签的主语言部分
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(tag, "-")
+	return primary
+}
+
+// 版本名称格式，会写入Cookie，仅允许字母、数字、下划线和连字符
+var variantNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// parseVariants 解析A/B版本文本，每行格式为“名称 权重 目标URL”
+func parseVariants(text string) ([]storage.Variant, error) {
+	var variants []storage.Variant
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("A/B版本第%d行格式错误，应为“名称 权重 目标URL”", i+1)
+		}
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("A/B版本第%d行权重必须是整数", i+1)
+		}
+
+		variants = append(variants, storage.Variant{
+			Name:      fields[0],
+			Weight:    weight,
+			TargetURL: fields[2],
+		})
+	}
+	return variants, nil
+}
+
+// formatVariants 将A/B版本格式化为表单文本
+func formatVariants(variants []storage.Variant) string {
+	var b strings.Builder
+	for _, v := range variants {
+		fmt.Fprintf(&b, "%s %d %s\n", v.Name, v.Weight, v.TargetURL)
+	}
+	return b.String()
+}
+
+// validateVariants 校验A/B版本，返回错误提示，通过时返回空字符串
+func validateVariants(variants []storage.Variant) string {
+	names := make(map[string]bool)
+	for _, v := range variants {
+		if !variantNameRegex.MatchString(v.Name) {
+			return fmt.Sprintf("无效的版本名称: %s，只能包含字母、数字、下划线和连字符", v.Name)
+		}
+		if names[v.Name] {
+			return fmt.Sprintf("版本名称重复: %s", v.Name)
+		}
+		names[v.Name] = true
+
+		if v.Weight < 0 {
+			return "版本权重不能为负数"
+		}
+		if v.TargetURL == "" {
+			return "版本的目标URL不能为空"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// 解锁Cookie名前缀，完整名称为前缀加短代码
+const unlockCookiePrefix = "go-shorten-unlock-"
+
+// isUnlocked 检查请求是否携带了该链接有效的解锁Cookie
+func (h *RedirectHTTPHandler) isUnlocked(r *http.Request, record *storage.URLRecord) bool {
+	cookie, err := r.Cookie(unlockCookiePrefix + record.ShortCode)
+	if err != nil {
+		return false
+	}
+
+	expiresStr, signature, found := strings.Cut(cookie.Value, ".")
+	if !found {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	expected := h.signUnlock(record, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// setUnlockCookie 写入解锁Cookie
+func (h *RedirectHTTPHandler) setUnlockCookie(w http.ResponseWriter, record *storage.URLRecord) {
+	expiresAt := time.Now().Add(h.cfg.UnlockTTL)
+	expires := expiresAt.Unix()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookiePrefix + record.ShortCode,
+		Value:    strconv.FormatInt(expires, 10) + "." + h.signUnlock(record, expires),
+		Path:     "/",
+		Expires:  expiresAt,
+		MaxAge:   int(h.cfg.UnlockTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// signUnlock 对短代码和过期时间签名，密码哈希参与签名，修改密码后旧的解锁Cookie随即失效
+func (h *RedirectHTTPHandler) signUnlock(record *storage.URLRecord, expires int64) string {
+	mac := hmac.New(sha256.New, h.cfg.UnlockSecret)
+	mac.Write([]byte(record.ShortCode))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(record.PasswordHash))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// handleUnlock 处理密码保护链接：展示解锁页面或校验提交的密码，解锁成功返回true
+func (h *RedirectHTTPHandler) handleUnlock(w http.ResponseWriter, r *http.Request, record *storage.URLRecord) bool {
+	if h.isUnlocked(r, record) {
+		return true
+	}
+
+	data := map[string]interface{}{
+		"title":     "访问受保护的链接",
+		"shortCode": record.ShortCode,
+		"action":    r.URL.RequestURI(),
+	}
+
+	if r.Method != http.MethodPost {
+		h.renderTemplate(w, "unlock.html", data, http.StatusOK)
+		return false
+	}
+
+	// 尝试次数按客户端IP和短代码统计，校验密码前先计数，并发猜测同样受限
+	limitKey := clientIP(r) + "|" + record.ShortCode
+	if !h.unlockLimiter.Reserve(limitKey) {
+		data["error"] = "密码错误次数过多，请稍后再试"
+		h.renderTemplate(w, "unlock.html", data, http.StatusTooManyRequests)
+		return false
+	}
+
+	if !record.CheckPassword(r.PostFormValue("password")) {
+		data["error"] = "密码错误"
+		h.renderTemplate(w, "unlock.html", data, http.StatusForbidden)
+		return false
+	}
+
+	// 解锁成功后重新以GET方式访问原地址
+	h.unlockLimiter.Reset(limitKey)
+	h.setUnlockCookie(w, record)
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+	return false
+}
+
+// clientIP 获取客户端IP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
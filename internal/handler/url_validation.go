@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yu1ec/go-shorten/internal/config"
+	"github.com/yu1ec/go-shorten/internal/reputation"
+	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/urlcheck"
+)
+
+// newURLValidator 根据配置创建目标地址校验器
+func newURLValidator(cfg *config.Config) *urlcheck.Validator {
+	return urlcheck.New(cfg.AllowedSchemes, cfg.MaxURLLength, cfg.DenyHosts, cfg.DenyPatterns)
+}
+
+// forEachURLField 依次处理记录中的所有地址字段，字段名与API请求体一致，optional表示该字段可以为空
+func forEachURLField(record *storage.URLRecord, fn func(field string, value *string, optional bool)) {
+	fn("target_url", &record.TargetURL, false)
+	fn("exhausted_url", &record.ExhaustedURL, true)
+	fn("og_image", &record.OGImage, true)
+	for i := range record.DeviceRules {
+		fn(fmt.Sprintf("device_rules[%d].target_url", i), &record.DeviceRules[i].TargetURL, false)
+	}
+	for i := range record.LanguageRules {
+		fn(fmt.Sprintf("language_rules[%d].target_url", i), &record.LanguageRules[i].TargetURL, false)
+	}
+	for i := range record.Variants {
+		fn(fmt.Sprintf("variants[%d].target_url", i), &record.Variants[i].TargetURL, false)
+	}
+}
+
+// normalizeRecordURLs 校验并规范化记录中的所有地址字段
+func normalizeRecordURLs(v *urlcheck.Validator, record *storage.URLRecord) urlcheck.Errors {
+	var errs urlcheck.Errors
+	forEachURLField(record, func(field string, value *string, optional bool) {
+		if optional && *value == "" {
+			return
+		}
+		normalized, err := v.Normalize(*value)
+		if err != nil {
+			errs = append(errs, urlcheck.FieldError{Field: field, Message: err.Error()})
+			return
+		}
+		*value = normalized
+	})
+	return errs
+}
+
+// checkRecordReputation 检查记录中访问者可能被跳转到的所有地址是否命中拦截列表
+func checkRecordReputation(checker reputation.Checker, record *storage.URLRecord) urlcheck.Errors {
+	var errs urlcheck.Errors
+	forEachURLField(record, func(field string, value *string, optional bool) {
+		if *value == "" || field == "og_image" {
+			return
+		}
+		if result := checker.Check(*value); result.Blocked {
+			errs = append(errs, urlcheck.FieldError{Field: field, Message: "地址已被拦截: " + result.Reason})
+		}
+	})
+	return errs
+}
+
+// formFieldErrors 将字段错误转换为表单字段到提示信息的映射，规则列表的错误归到对应的文本框并注明行号
+func formFieldErrors(errs urlcheck.Errors) map[string]string {
+	fields := make(map[string]string)
+	for _, e := range errs {
+		field, msg := e.Field, e.Message
+		if name, rest, found := strings.Cut(e.Field, "["); found {
+			var index int
+			fmt.Sscanf(rest, "%d]", &index)
+			field, msg = name, fmt.Sprintf("第%d行: %s", index+1, e.Message)
+		}
+		if existing, ok := fields[field]; ok {
+			msg = existing + "；" + msg
+		}
+		fields[field] = msg
+	}
+	return fields
+}
@@ -1,22 +1,106 @@
 package handler
 
 import (
-	"crypto/rand"
-	"math/big"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/yu1ec/go-shorten/internal/codegen"
+	"github.com/yu1ec/go-shorten/internal/codepolicy"
+	"github.com/yu1ec/go-shorten/internal/storage"
+	"github.com/yu1ec/go-shorten/internal/urlcheck"
+	html_templates "github.com/yu1ec/go-shorten/templates"
 )
 
-// 用于生成随机短代码的字符集
-const Charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+// generateCode 为指定域名生成未被使用的短代码，已删除、不符合短代码规则或在pending中的同样视为已被使用
+func generateCode(gen codegen.Generator, policy *codepolicy.Policy, length int, urlStorage *storage.URLStorage, domain string, pending map[string]bool) (string, error) {
+	return codegen.Unique(gen, length, func(code string) bool {
+		if !policy.Allowed(code) || pending[code] {
+			return true
+		}
+		_, err := urlStorage.GetURL(domain, code)
+		return err == nil || urlStorage.IsDeleted(domain, code)
+	})
+}
+
+// parseAliases 解析以逗号或空白分隔的别名列表
+func parseAliases(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '，' || unicode.IsSpace(r)
+	})
+}
+
+// validateAliases 按短代码规则校验别名，别名不能与短代码相同
+func validateAliases(policy *codepolicy.Policy, record storage.URLRecord) urlcheck.Errors {
+	var errs urlcheck.Errors
+	for _, alias := range record.Aliases {
+		if alias == record.ShortCode {
+			errs = append(errs, urlcheck.FieldError{Field: "aliases", Message: fmt.Sprintf("别名 %s 与短链接代码相同", alias)})
+			continue
+		}
+		if err := policy.Validate(alias); err != nil {
+			errs = append(errs, urlcheck.FieldError{Field: "aliases", Message: fmt.Sprintf("别名 %s: %s", alias, err.Error())})
+		}
+	}
+	return errs
+}
+
+// 反引号包围的代码片段
+var inlineCodeRegex = regexp.MustCompile("`([^`]+)`")
 
-// GenerateRandomCode 生成指定长度的随机短代码
-func GenerateRandomCode(length int) (string, error) {
-	b := make([]byte, length)
-	for i := range b {
-		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(Charset))))
+// templateFuncs 模板中可用的辅助函数
+var templateFuncs = template.FuncMap{
+	// shortLink 短链接在管理界面中的链接地址，其他域名的记录使用协议相对地址
+	"shortLink": func(record storage.URLRecord) string {
+		if record.Domain != "" {
+			return "//" + record.Domain + "/" + record.ShortCode
+		}
+		return "/" + record.ShortCode
+	},
+
+	// inlineCode 转义文本，并将反引号包围的内容显示为代码，用于API文档中的说明
+	"inlineCode": func(text string) template.HTML {
+		escaped := template.HTMLEscapeString(text)
+		return template.HTML(inlineCodeRegex.ReplaceAllString(escaped, "<code>$1</code>"))
+	},
+
+	// percent 计算占比并格式化为百分数
+	"percent": func(part, total int64) string {
+		if total == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+	},
+}
+
+// parseStandaloneTemplates 解析不需要layout的独立模板
+func parseStandaloneTemplates(files ...string) map[string]*template.Template {
+	templates := make(map[string]*template.Template)
+	for _, file := range files {
+		content, err := html_templates.AdminUIFS.ReadFile(file)
 		if err != nil {
-			return "", err
+			log.Fatalf("读取%s失败: %v", file, err)
 		}
-		b[i] = Charset[num.Int64()]
+
+		templates[file] = template.Must(template.New(file).Funcs(templateFuncs).Parse(string(content)))
+	}
+	return templates
+}
+
+// shortURLFor 生成短链接的完整地址，记录属于默认域名时使用请求的域名
+func shortURLFor(r *http.Request, domain, shortCode string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	host := r.Host
+	if domain != "" {
+		host = domain
 	}
-	return string(b), nil
+	return scheme + "://" + host + "/" + shortCode
 }
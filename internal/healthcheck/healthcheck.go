@@ -0,0 +1,191 @@
+// Package healthcheck 定时检查短链接目标地址是否仍可访问
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// maxRedirects 跟随重定向的最大次数，与http.Client的默认值相同
+const maxRedirects = 10
+
+// Checker 目标地址健康检查器
+type Checker struct {
+	client       *http.Client
+	concurrency  int
+	hostInterval time.Duration
+
+	mutex    sync.Mutex
+	hostNext map[string]time.Time // 每个域名下一次允许请求的时间
+}
+
+// New 创建检查器，concurrency为同时检查的地址数量，hostInterval为同一域名两次请求的最小间隔
+// 跟随重定向时每一跳同样遵守域名请求间隔，client本身不会被修改
+func New(client *http.Client, concurrency int, hostInterval time.Duration) *Checker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	c := &Checker{
+		concurrency:  concurrency,
+		hostInterval: hostInterval,
+		hostNext:     make(map[string]time.Time),
+	}
+
+	limited := *client
+	checkRedirect := client.CheckRedirect
+	limited.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if checkRedirect != nil {
+			if err := checkRedirect(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= maxRedirects {
+			return fmt.Errorf("重定向超过%d次", maxRedirects)
+		}
+		return c.waitHost(req.Context(), hostOf(req.URL.String()))
+	}
+	c.client = &limited
+	return c
+}
+
+// Check 检查单个地址，先发送HEAD请求，失败或服务器不支持时改用GET请求，
+// 每个请求发送前都会等待同一域名的请求间隔
+func (c *Checker) Check(ctx context.Context, target string) storage.HealthStatus {
+	status, latency, err := c.request(ctx, http.MethodHead, target)
+	if (err != nil || status >= 400) && ctx.Err() == nil {
+		status, latency, err = c.request(ctx, http.MethodGet, target)
+	}
+
+	health := storage.HealthStatus{
+		StatusCode: status,
+		LatencyMS:  latency.Milliseconds(),
+		CheckedAt:  time.Now(),
+	}
+	if err != nil {
+		health.Error = err.Error()
+	}
+	return health
+}
+
+// request 等待域名请求间隔后发送请求，返回最终的状态码和耗时，跟随重定向
+func (c *Checker) request(ctx context.Context, method, target string) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("User-Agent", "go-shorten-healthcheck/1.0")
+
+	if err := c.waitHost(ctx, hostOf(target)); err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	defer resp.Body.Close()
+
+	// 只读取少量内容，避免下载大文件
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, time.Since(start), nil
+}
+
+// Run 检查所有短链接的目标地址并保存结果，已隔离的链接和非HTTP地址不检查
+func (c *Checker) Run(ctx context.Context, urlStorage *storage.URLStorage) error {
+	records, err := urlStorage.GetAllURLs()
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan storage.URLRecord)
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range jobs {
+				health := c.Check(ctx, record.TargetURL)
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := urlStorage.SetHealth(record.Domain, record.ShortCode, record.TargetURL, health); err != nil {
+					log.Printf("保存健康检查结果失败: %s: %v\n", record.ShortCode, err)
+				}
+			}
+		}()
+	}
+
+	for _, record := range records {
+		if record.IsQuarantined() || hostOf(record.TargetURL) == "" {
+			continue
+		}
+		select {
+		case jobs <- record:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// Start 启动后台任务，启动后立即检查一次，之后按间隔定时检查
+func (c *Checker) Start(urlStorage *storage.URLStorage, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.Run(context.Background(), urlStorage); err != nil {
+				log.Printf("健康检查失败: %v\n", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// waitHost 等待直到可以向该域名发送下一个请求
+func (c *Checker) waitHost(ctx context.Context, host string) error {
+	c.mutex.Lock()
+	now := time.Now()
+	// 清理已过期的记录，避免检查过的域名越积越多
+	for h, next := range c.hostNext {
+		if !next.After(now) {
+			delete(c.hostNext, h)
+		}
+	}
+	next, exists := c.hostNext[host]
+	if !exists {
+		next = now
+	}
+	c.hostNext[host] = next.Add(c.hostInterval)
+	c.mutex.Unlock()
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// hostOf 返回HTTP地址的域名，非HTTP地址返回空字符串
+func hostOf(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,184 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCheckFallsBackToGet HEAD请求失败时改用GET请求，并记录GET请求的状态码和耗时
+func TestCheckFallsBackToGet(t *testing.T) {
+	var mutex sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		methods = append(methods, r.Method)
+		mutex.Unlock()
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	checker := New(server.Client(), 1, 0)
+	health := checker.Check(context.Background(), server.URL)
+
+	if len(methods) != 2 || methods[0] != http.MethodHead || methods[1] != http.MethodGet {
+		t.Fatalf("请求顺序应为HEAD、GET，实际为%v", methods)
+	}
+	if health.StatusCode != http.StatusNoContent {
+		t.Errorf("状态码应为%d，实际为%d", http.StatusNoContent, health.StatusCode)
+	}
+	if health.LatencyMS < 20 {
+		t.Errorf("耗时应不少于20ms，实际为%dms", health.LatencyMS)
+	}
+	if health.Error != "" {
+		t.Errorf("不应记录错误，实际为%q", health.Error)
+	}
+	if health.CheckedAt.IsZero() {
+		t.Error("应记录检查时间")
+	}
+}
+
+// TestCheckHeadSuccess HEAD请求成功时不再发送GET请求
+func TestCheckHeadSuccess(t *testing.T) {
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if r.Method != http.MethodHead {
+			t.Errorf("不应发送%s请求", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	health := New(server.Client(), 1, 0).Check(context.Background(), server.URL)
+	if health.StatusCode != http.StatusOK || count != 1 {
+		t.Errorf("应只发送一次HEAD请求并返回200，实际请求%d次，状态码%d", count, health.StatusCode)
+	}
+}
+
+// TestCheckUnreachable 无法连接时记录错误
+func TestCheckUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	target := server.URL
+	server.Close()
+
+	health := New(http.DefaultClient, 1, 0).Check(context.Background(), target)
+	if health.Error == "" || health.StatusCode != 0 {
+		t.Errorf("应记录连接错误，实际为%+v", health)
+	}
+}
+
+// TestCheckHostInterval 同一域名的每个请求（包括GET回退请求）之间都保持最小间隔
+func TestCheckHostInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	var mutex sync.Mutex
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		times = append(times, time.Now())
+		mutex.Unlock()
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	checker := New(server.Client(), 2, interval)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.Check(context.Background(), server.URL)
+		}()
+	}
+	wg.Wait()
+
+	if len(times) != 4 {
+		t.Fatalf("应发送4个请求，实际为%d个", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		// 允许少量计时误差
+		if gap := times[i].Sub(times[i-1]); gap < interval-5*time.Millisecond {
+			t.Errorf("第%d个请求与上一个请求间隔%v，应不少于%v", i+1, gap, interval)
+		}
+	}
+}
+
+// TestCheckCanceled 等待域名间隔时取消检查会立即返回
+func TestCheckCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	checker := New(server.Client(), 1, time.Hour)
+	checker.Check(context.Background(), server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	health := checker.Check(ctx, server.URL)
+	if health.Error == "" {
+		t.Error("取消后应记录错误")
+	}
+}
+
+// TestCheckRedirectHostInterval 跟随重定向时每一跳也保持同一域名的请求间隔
+func TestCheckRedirectHostInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	var mutex sync.Mutex
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		times = append(times, time.Now())
+		mutex.Unlock()
+
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	health := New(server.Client(), 1, interval).Check(context.Background(), server.URL+"/a")
+	if health.StatusCode != http.StatusOK {
+		t.Fatalf("状态码应为200，实际为%d", health.StatusCode)
+	}
+	if len(times) != 3 {
+		t.Fatalf("应发送3个请求，实际为%d个", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < interval-5*time.Millisecond {
+			t.Errorf("第%d跳与上一跳间隔%v，应不少于%v", i+1, gap, interval)
+		}
+	}
+}
+
+// TestHostNextPruned 过了请求间隔的域名记录会被清理
+func TestHostNextPruned(t *testing.T) {
+	checker := New(http.DefaultClient, 1, time.Millisecond)
+	for _, host := range []string{"a.example", "b.example", "c.example"} {
+		if err := checker.waitHost(context.Background(), host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := checker.waitHost(context.Background(), "d.example"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(checker.hostNext) != 1 {
+		t.Errorf("应只保留1个域名记录，实际为%v", checker.hostNext)
+	}
+}
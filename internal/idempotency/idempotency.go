@@ -0,0 +1,148 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 幂等键相关的错误，使用errors.Is判断，面向用户的提示信息由处理器生成
+var (
+	// ErrMismatch 幂等键已用于内容不同的请求
+	ErrMismatch = errors.New("idempotency key reused with a different request")
+
+	// ErrInProgress 使用同一幂等键的请求仍在处理中
+	ErrInProgress = errors.New("idempotency key in progress")
+)
+
+// Response 保存的响应，重试时原样返回
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// entry 单个幂等键的记录，response为nil表示请求仍在处理中
+type entry struct {
+	fingerprint [sha256.Size]byte
+	response    *Response
+	expiresAt   time.Time
+}
+
+// Store 幂等键存储，在有效期内记录每个键对应的请求内容和响应
+type Store struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]*entry
+}
+
+// NewStore 创建幂等键存储，响应保存ttl时长
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Begin 开始处理带幂等键的请求
+// 键已有保存的响应时返回该响应；请求内容不同时返回ErrMismatch，仍在处理中时返回ErrInProgress；
+// 否则占用该键并返回nil，调用方处理完成后必须调用Complete或Release
+func (s *Store) Begin(key string, body []byte) (*Response, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fingerprint := sha256.Sum256(body)
+	now := time.Now()
+	if e, exists := s.entries[key]; exists && now.Before(e.expiresAt) {
+		if e.fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		if e.response == nil {
+			return nil, ErrInProgress
+		}
+		return e.response, nil
+	}
+
+	s.entries[key] = &entry{fingerprint: fingerprint, expiresAt: now.Add(s.ttl)}
+	return nil, nil
+}
+
+// Complete 保存键对应的响应，有效期从此时开始计算
+func (s *Store) Complete(key string, response Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, exists := s.entries[key]; exists {
+		e.response = &response
+		e.expiresAt = time.Now().Add(s.ttl)
+	}
+}
+
+// Release 放弃占用的键，之后使用该键的请求会重新处理
+func (s *Store) Release(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, exists := s.entries[key]; exists && e.response == nil {
+		delete(s.entries, key)
+	}
+}
+
+// GC 清理已过期的记录
+func (s *Store) GC() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for key, e := range s.entries {
+		if now.After(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// StartGCTimer 启动定时清理
+func (s *Store) StartGCTimer() {
+	go func() {
+		ticker := time.NewTicker(s.ttl)
+		for {
+			<-ticker.C
+			s.GC()
+		}
+	}()
+}
+
+// Recorder 记录写入的响应，同时转发给原始的ResponseWriter
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+// NewRecorder 包装ResponseWriter
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader 记录状态码
+func (r *Recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write 记录响应内容
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.body = append(r.body, b...)
+	return r.ResponseWriter.Write(b)
+}
+
+// Response 返回已记录的响应
+func (r *Recorder) Response() Response {
+	return Response{
+		Status:      r.status,
+		ContentType: r.Header().Get("Content-Type"),
+		Body:        r.body,
+	}
+}
@@ -0,0 +1,57 @@
+package qrcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// WritePNG 输出 PNG 图片，size 为期望的图片边长（像素），margin 为空白边距（模块数）
+// 每个模块取整数像素，实际边长为不超过 size 的最大整数倍，最小为每模块 1 像素
+func (c *Code) WritePNG(w io.Writer, size, margin int) error {
+	dim := c.size + margin*2
+	scale := max(1, size/dim)
+
+	palette := color.Palette{color.White, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, dim*scale, dim*scale), palette)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			px, py := (x+margin)*scale, (y+margin)*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(px+dx, py+dy, 1)
+				}
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// SVG 输出 SVG 图片，size 为图片边长（像素），margin 为空白边距（模块数）
+func (c *Code) SVG(size, margin int) string {
+	dim := c.size + margin*2
+
+	var path strings.Builder
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, dim, dim)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`, dim, dim)
+	fmt.Fprintf(&b, `<path d="%s" fill="#000000"/>`, path.String())
+	b.WriteString("</svg>\n")
+	return b.String()
+}
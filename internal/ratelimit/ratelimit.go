@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// window 单个键在当前窗口内的计数
+type window struct {
+	count   int
+	resetAt time.Time
+}
+
+// Limiter 固定窗口计数限流器，按键统计窗口期内的事件次数
+type Limiter struct {
+	mutex   sync.Mutex
+	limit   int
+	period  time.Duration
+	windows map[string]*window
+}
+
+// NewLimiter 创建限流器，每个键在period内最多允许limit次事件
+func NewLimiter(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		period:  period,
+		windows: make(map[string]*window),
+	}
+}
+
+// Reserve 检查键在当前窗口内是否还未达到上限，未达到时计数一次并返回true
+// 检查与计数在同一把锁内完成，并发请求不会超出上限
+func (l *Limiter) Reserve(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	win, exists := l.windows[key]
+	if !exists || now.After(win.resetAt) {
+		win = &window{resetAt: now.Add(l.period)}
+		l.windows[key] = win
+	}
+	if win.count >= l.limit {
+		return false
+	}
+	win.count++
+	return true
+}
+
+// Reset 清除键的计数
+func (l *Limiter) Reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.windows, key)
+}
+
+// GC 清理已过期的窗口
+func (l *Limiter) GC() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	for key, win := range l.windows {
+		if now.After(win.resetAt) {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// StartGCTimer 启动定时清理
+func (l *Limiter) StartGCTimer() {
+	go func() {
+		ticker := time.NewTicker(l.period)
+		for {
+			<-ticker.C
+			l.GC()
+		}
+	}()
+}
@@ -0,0 +1,219 @@
+package reputation
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blocklist 基于本地拦截列表文件的信誉检查，目录中的每个文件为一个列表
+// 文件每行一条规则，支持以下格式，# 之后为注释：
+//   - hosts文件格式：0.0.0.0 evil.example（同一行可以有多个域名）
+//   - 域名列表：evil.example，同时匹配其子域名
+//   - 完整地址：https://example.com/phishing，匹配以该地址开头的目标地址
+type Blocklist struct {
+	dir string
+
+	mutex   sync.RWMutex
+	hosts   map[string]string // 域名 -> 来源文件
+	urls    map[string]string // 地址前缀 -> 来源文件
+	modTime map[string]time.Time
+}
+
+// NewBlocklist 从目录加载拦截列表，目录不存在时为空列表
+func NewBlocklist(dir string) (*Blocklist, error) {
+	b := &Blocklist{dir: dir}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Check 实现Checker接口
+func (b *Blocklist) Check(target string) Result {
+	u, err := url.Parse(target)
+	if err != nil {
+		return Result{}
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	// 逐级检查域名及其上级域名
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for host != "" {
+		if source, ok := b.hosts[host]; ok {
+			return Result{Blocked: true, Reason: fmt.Sprintf("域名 %s 在拦截列表 %s 中", host, source)}
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+
+	// 多个地址前缀命中时取最长的一个，长度相同时按字典序，保证每次检查的原因相同
+	lower := strings.ToLower(target)
+	matched := ""
+	for prefix := range b.urls {
+		if !strings.HasPrefix(lower, prefix) {
+			continue
+		}
+		if len(prefix) > len(matched) || (len(prefix) == len(matched) && prefix < matched) {
+			matched = prefix
+		}
+	}
+	if matched != "" {
+		return Result{Blocked: true, Reason: fmt.Sprintf("地址 %s 在拦截列表 %s 中", matched, b.urls[matched])}
+	}
+
+	return Result{}
+}
+
+// Reload 重新加载目录中的所有列表文件
+func (b *Blocklist) Reload() error {
+	files, err := b.listFiles()
+	if err != nil {
+		return err
+	}
+
+	hosts := make(map[string]string)
+	urls := make(map[string]string)
+	modTime := make(map[string]time.Time)
+	// 按文件名顺序加载，同一规则出现在多个文件中时来源固定为最后一个文件
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := parseBlocklistFile(path, hosts, urls); err != nil {
+			return fmt.Errorf("加载拦截列表%s失败: %w", path, err)
+		}
+		modTime[path] = files[path]
+	}
+
+	b.mutex.Lock()
+	b.hosts, b.urls, b.modTime = hosts, urls, modTime
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// StartReloader 定时检查列表文件，文件新增、删除或修改后自动重新加载
+func (b *Blocklist) StartReloader(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if !b.changed() {
+				continue
+			}
+			if err := b.Reload(); err != nil {
+				log.Printf("重新加载拦截列表失败: %v\n", err)
+				continue
+			}
+			log.Println("拦截列表已重新加载")
+		}
+	}()
+}
+
+// Size 返回已加载的域名规则和地址规则数量
+func (b *Blocklist) Size() (int, int) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.hosts), len(b.urls)
+}
+
+// changed 判断列表文件是否有变化
+func (b *Blocklist) changed() bool {
+	files, err := b.listFiles()
+	if err != nil {
+		return false
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if len(files) != len(b.modTime) {
+		return true
+	}
+	for path, mtime := range files {
+		if !b.modTime[path].Equal(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// listFiles 列出目录中的列表文件及其修改时间
+func (b *Blocklist) listFiles() (map[string]time.Time, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取拦截列表目录失败: %w", err)
+	}
+
+	files := make(map[string]time.Time)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[filepath.Join(b.dir, entry.Name())] = info.ModTime()
+	}
+	return files, nil
+}
+
+// parseBlocklistFile 解析单个列表文件，将规则写入hosts和urls
+func parseBlocklistFile(path string, hosts, urls map[string]string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	source := filepath.Base(path)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(strings.ToLower(line))
+		if len(fields) == 0 {
+			continue
+		}
+
+		// 完整地址
+		if strings.Contains(fields[0], "://") {
+			urls[fields[0]] = source
+			continue
+		}
+
+		// hosts文件格式：第一列为IP地址
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+		for _, host := range fields {
+			host = strings.TrimSuffix(host, ".")
+			if host == "" || host == "localhost" || host == "localhost.localdomain" || host == "broadcasthost" {
+				continue
+			}
+			hosts[host] = source
+		}
+	}
+
+	return scanner.Err()
+}
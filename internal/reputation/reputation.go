@@ -0,0 +1,23 @@
+// Package reputation 检查目标地址的信誉，拦截钓鱼、恶意软件等地址
+package reputation
+
+// Result 单个地址的检查结果
+type Result struct {
+	Blocked bool
+	Reason  string // 命中的规则及来源，用于提示和排查
+}
+
+// Checker 地址信誉检查接口，可替换为调用外部服务的实现
+type Checker interface {
+	Check(target string) Result
+}
+
+// CheckAll 依次检查多个地址，返回第一个被拦截的结果
+func CheckAll(c Checker, targets []string) Result {
+	for _, target := range targets {
+		if result := c.Check(target); result.Blocked {
+			return result
+		}
+	}
+	return Result{}
+}
@@ -0,0 +1,52 @@
+package reputation
+
+import (
+	"log"
+	"time"
+
+	"github.com/yu1ec/go-shorten/internal/storage"
+)
+
+// Scan 检查所有短链接，隔离命中拦截列表的链接，并解除已不再命中的链接的隔离
+func Scan(urlStorage *storage.URLStorage, checker Checker) (quarantined, released int, err error) {
+	records, err := urlStorage.GetAllURLs()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, record := range records {
+		result := CheckAll(checker, record.DestinationURLs())
+		if result.Reason == record.QuarantineReason {
+			continue
+		}
+
+		if err := urlStorage.SetQuarantine(record.Domain, record.ShortCode, result.Reason); err != nil {
+			log.Printf("更新链接隔离状态失败: %s: %v\n", record.ShortCode, err)
+			continue
+		}
+		if result.Blocked {
+			log.Printf("链接已隔离: %s: %s\n", record.ShortCode, result.Reason)
+			quarantined++
+		} else {
+			log.Printf("链接已解除隔离: %s\n", record.ShortCode)
+			released++
+		}
+	}
+
+	return quarantined, released, nil
+}
+
+// StartScanner 启动后立即扫描一次，之后定时扫描所有短链接
+func StartScanner(urlStorage *storage.URLStorage, checker Checker, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, _, err := Scan(urlStorage, checker); err != nil {
+				log.Printf("扫描短链接失败: %v\n", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
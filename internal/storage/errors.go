@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 存储层的错误类型，使用errors.Is判断，面向用户的提示信息由处理器生成
+var (
+	// ErrNotFound 链接或版本不存在
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict 短代码或别名已被其他链接使用
+	ErrConflict = errors.New("conflict")
+
+	// ErrInvalid 参数不合法，如别名重复、新短代码与原短代码相同
+	ErrInvalid = errors.New("invalid")
+
+	// ErrBatchAborted 批量创建要求全部成功，因其他记录失败而未创建
+	ErrBatchAborted = errors.New("batch aborted")
+
+	// ErrClicksExhausted 链接访问次数已用完
+	ErrClicksExhausted = errors.New("clicks exhausted")
+)
+
+// 与错误相关的字段
+const (
+	FieldShortCode = "short_code"
+	FieldAlias     = "alias"
+	FieldVariant   = "variant"
+)
+
+// Error 带上下文的存储层错误，Kind为ErrNotFound、ErrConflict或ErrInvalid，
+// Field和Value为出错的字段及其取值
+type Error struct {
+	Kind  error
+	Field string
+	Value string
+}
+
+// Error 实现error接口
+func (e *Error) Error() string {
+	return fmt.Sprintf("storage: %s %q: %v", e.Field, e.Value, e.Kind)
+}
+
+// Unwrap 返回错误类型，用于errors.Is
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// notFound 链接不存在
+func notFound(code string) error {
+	return &Error{Kind: ErrNotFound, Field: FieldShortCode, Value: code}
+}
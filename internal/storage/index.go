@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// names 返回记录的所有访问名称：短代码及其别名
+func (r URLRecord) names() []recordKey {
+	keys := []recordKey{r.key()}
+	for _, alias := range r.Aliases {
+		keys = append(keys, recordKey{domain: r.Domain, code: alias})
+	}
+	return keys
+}
+
+// fold 不区分大小写时将短代码转为小写，作为名称索引的键
+func (s *URLStorage) fold(key recordKey) recordKey {
+	if s.caseInsensitive {
+		key.code = strings.ToLower(key.code)
+	}
+	return key
+}
+
+// resolve 根据短代码或别名查找记录的主键
+func (s *URLStorage) resolve(domain, code string) (recordKey, bool) {
+	key, exists := s.names[s.fold(recordKey{domain: domain, code: code})]
+	return key, exists
+}
+
+// buildIndex 根据所有记录重建名称索引和目标地址索引，不同记录的名称冲突时返回错误
+func (s *URLStorage) buildIndex() error {
+	s.names = make(map[recordKey]recordKey)
+	s.targets = make(map[string]map[recordKey]bool)
+	s.lengths = make(map[lengthKey]map[recordKey]bool)
+
+	var conflicts []string
+	for key, record := range s.cache {
+		s.addTarget(*record)
+		for _, name := range record.names() {
+			folded := s.fold(name)
+			if owner, exists := s.names[folded]; exists && owner != key {
+				conflicts = append(conflicts, fmt.Sprintf("%s 与 %s", displayKey(name), displayKey(owner)))
+				continue
+			}
+			s.names[folded] = key
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		if s.caseInsensitive {
+			return fmt.Errorf("不区分大小写后以下短代码冲突，请先修改后再启用: %s", strings.Join(conflicts, "；"))
+		}
+		return fmt.Errorf("以下短代码或别名冲突: %s", strings.Join(conflicts, "；"))
+	}
+	return nil
+}
+
+// checkNames 检查记录的名称是否已被其他记录使用，owner为记录自身的主键
+func (s *URLStorage) checkNames(record URLRecord, owner recordKey) error {
+	seen := make(map[recordKey]bool)
+	for i, name := range record.names() {
+		folded := s.fold(name)
+		if seen[folded] {
+			return &Error{Kind: ErrInvalid, Field: FieldAlias, Value: name.code}
+		}
+		seen[folded] = true
+
+		if existing, exists := s.names[folded]; exists && existing != owner {
+			if i == 0 {
+				return &Error{Kind: ErrConflict, Field: FieldShortCode, Value: name.code}
+			}
+			return &Error{Kind: ErrConflict, Field: FieldAlias, Value: name.code}
+		}
+	}
+	return nil
+}
+
+// indexRecord 将记录的所有名称和目标地址加入索引
+func (s *URLStorage) indexRecord(record URLRecord) {
+	for _, name := range record.names() {
+		s.names[s.fold(name)] = record.key()
+	}
+	s.addTarget(record)
+}
+
+// unindexRecord 从索引中移除记录的所有名称和目标地址
+func (s *URLStorage) unindexRecord(record URLRecord) {
+	for _, name := range record.names() {
+		folded := s.fold(name)
+		if s.names[folded] == record.key() {
+			delete(s.names, folded)
+		}
+	}
+
+	if keys := s.targets[record.TargetURL]; keys != nil {
+		delete(keys, record.key())
+		if len(keys) == 0 {
+			delete(s.targets, record.TargetURL)
+		}
+	}
+
+	lk := record.lengthKey()
+	if keys := s.lengths[lk]; keys != nil {
+		delete(keys, record.key())
+		if len(keys) == 0 {
+			delete(s.lengths, lk)
+		}
+	}
+}
+
+// addTarget 将记录加入目标地址索引和短代码长度索引
+func (s *URLStorage) addTarget(record URLRecord) {
+	keys := s.targets[record.TargetURL]
+	if keys == nil {
+		keys = make(map[recordKey]bool)
+		s.targets[record.TargetURL] = keys
+	}
+	keys[record.key()] = true
+
+	lk := record.lengthKey()
+	if s.lengths[lk] == nil {
+		s.lengths[lk] = make(map[recordKey]bool)
+	}
+	s.lengths[lk][record.key()] = true
+}
+
+// lengthKey 短代码长度索引的键
+type lengthKey struct {
+	domain string
+	length int
+}
+
+// lengthKey 返回记录在短代码长度索引中的键，长度按字符计算
+func (r URLRecord) lengthKey() lengthKey {
+	return lengthKey{domain: r.Domain, length: utf8.RuneCountInString(r.ShortCode)}
+}
+
+// FindByCodeLength 返回域名下短代码长度（按字符计）在[minLength, maxLength]之间的全部记录，按短代码排序，
+// 用于推荐相近的短代码，只遍历长度相近的记录而不是全部记录
+func (s *URLStorage) FindByCodeLength(domain string, minLength, maxLength int) []URLRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var records []URLRecord
+	for length := minLength; length <= maxLength; length++ {
+		for key := range s.lengths[lengthKey{domain: domain, length: length}] {
+			records = append(records, *s.cache[key])
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ShortCode < records[j].ShortCode
+	})
+	return records
+}
+
+// FindByTarget 查找域名下目标地址完全相同的短链接，按创建时间排序
+// targetURL需要是规范化后的地址，与保存时的规范化方式一致
+func (s *URLStorage) FindByTarget(domain, targetURL string) []URLRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var records []URLRecord
+	for key := range s.targets[targetURL] {
+		if key.domain == domain {
+			records = append(records, *s.cache[key])
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreateTime.Equal(records[j].CreateTime) {
+			return records[i].CreateTime.Before(records[j].CreateTime)
+		}
+		return records[i].ShortCode < records[j].ShortCode
+	})
+	return records
+}
+
+// displayKey 用于错误信息的记录名称
+func displayKey(key recordKey) string {
+	if key.domain == "" {
+		return key.code
+	}
+	return key.domain + "/" + key.code
+}
+
+// HasName 判断code是否为记录的短代码或别名，不区分大小写时忽略大小写
+func (s *URLStorage) HasName(record URLRecord, code string) bool {
+	target := s.fold(recordKey{domain: record.Domain, code: code})
+	for _, name := range record.names() {
+		if s.fold(name) == target {
+			return true
+		}
+	}
+	return false
+}
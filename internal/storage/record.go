@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 查询参数冲突策略：请求参数与目标URL参数同名时的处理方式
+const (
+	QueryConflictKeepTarget = "keep_target" // 保留目标URL中的参数（默认）
+	QueryConflictOverride   = "override"    // 使用请求中的参数覆盖
+	QueryConflictAppend     = "append"      // 两者同时保留
+)
+
+// Tombstone 已删除的短链接，访问时返回410而不是404
+type Tombstone struct {
+	Domain    string    `json:"domain,omitempty"`
+	ShortCode string    `json:"short_code"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// URLRecord 表示一个短链接记录
+type URLRecord struct {
+	Domain     string    `json:"domain,omitempty"` // 所属域名，为空表示默认域名
+	ShortCode  string    `json:"short_code"`
+	Aliases    []string  `json:"aliases,omitempty"` // 别名，访问别名等同于访问短代码，共享访问统计
+	TargetURL  string    `json:"target_url"`
+	Remark     string    `json:"remark"`
+	CreateTime time.Time `json:"create_time"`
+
+	// 跳转选项
+	PassQuery     bool   `json:"pass_query,omitempty"`     // 是否将请求中的查询参数追加到目标URL
+	QueryConflict string `json:"query_conflict,omitempty"` // 查询参数冲突策略
+	PrefixMatch   bool   `json:"prefix_match,omitempty"`   // 前缀模式：/{code}/xxx 跳转到 TargetURL + "/xxx"
+
+	// 访问密码的bcrypt哈希，为空表示不需要密码
+	PasswordHash string `json:"password_hash,omitempty"`
+
+	// 访问次数限制，MaxClicks为0表示不限次数
+	MaxClicks    int    `json:"max_clicks,omitempty"`
+	ClickCount   int    `json:"click_count,omitempty"`   // 已成功跳转次数，仅在限制次数时统计
+	ExhaustedURL string `json:"exhausted_url,omitempty"` // 次数用完后的跳转地址，为空时返回410
+
+	// 跳转前总是展示提示页，倒计时后再跳转
+	Interstitial bool `json:"interstitial,omitempty"`
+
+	// 按设备平台跳转的规则，按顺序匹配，第一条命中的规则生效
+	DeviceRules []DeviceRule `json:"device_rules,omitempty"`
+
+	// 按 Accept-Language 跳转的规则，选择访问者偏好程度最高且有规则匹配的语言
+	LanguageRules []LanguageRule `json:"language_rules,omitempty"`
+
+	// A/B 分流：按权重在多个目标地址间分配流量，StickyVariant 开启时同一访问者固定访问同一版本
+	Variants      []Variant `json:"variants,omitempty"`
+	StickyVariant bool      `json:"sticky_variant,omitempty"`
+
+	// 社交平台链接预览（Open Graph / Twitter Card）
+	OGTitle       string `json:"og_title,omitempty"`
+	OGDescription string `json:"og_description,omitempty"`
+	OGImage       string `json:"og_image,omitempty"`
+
+	// 目标地址命中拦截列表后被隔离，访问时展示警告页而不跳转
+	QuarantineReason string     `json:"quarantine_reason,omitempty"`
+	QuarantinedAt    *time.Time `json:"quarantined_at,omitempty"`
+
+	// 最近一次目标地址健康检查的结果，修改目标地址后清空
+	Health *HealthStatus `json:"health,omitempty"`
+}
+
+// HealthStatus 目标地址健康检查结果
+type HealthStatus struct {
+	StatusCode int       `json:"status_code,omitempty"` // 最终响应的状态码，请求失败时为0
+	LatencyMS  int64     `json:"latency_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Error      string    `json:"error,omitempty"` // 请求失败的原因
+}
+
+// IsBroken 目标地址是否无法访问
+func (h HealthStatus) IsBroken() bool {
+	return h.Error != "" || h.StatusCode >= 400
+}
+
+// IsBroken 最近一次健康检查是否发现目标地址无法访问
+func (r URLRecord) IsBroken() bool {
+	return r.Health != nil && r.Health.IsBroken()
+}
+
+// IsQuarantined 是否已被隔离
+func (r URLRecord) IsQuarantined() bool {
+	return r.QuarantineReason != ""
+}
+
+// DestinationURLs 返回访问者可能被跳转到的所有地址
+func (r URLRecord) DestinationURLs() []string {
+	urls := []string{r.TargetURL}
+	if r.ExhaustedURL != "" {
+		urls = append(urls, r.ExhaustedURL)
+	}
+	for _, rule := range r.DeviceRules {
+		urls = append(urls, rule.TargetURL)
+	}
+	for _, rule := range r.LanguageRules {
+		urls = append(urls, rule.TargetURL)
+	}
+	for _, v := range r.Variants {
+		urls = append(urls, v.TargetURL)
+	}
+	return urls
+}
+
+// HasOpenGraph 是否设置了链接预览信息
+func (r URLRecord) HasOpenGraph() bool {
+	return r.OGTitle != "" || r.OGDescription != "" || r.OGImage != ""
+}
+
+// Variant A/B 分流中的一个版本
+type Variant struct {
+	Name      string `json:"name"`
+	TargetURL string `json:"target_url"`
+	Weight    int    `json:"weight"`
+	Hits      int64  `json:"hits"` // 该版本被访问的次数
+}
+
+// TotalVariantHits 所有版本的访问次数之和
+func (r URLRecord) TotalVariantHits() int64 {
+	var total int64
+	for _, v := range r.Variants {
+		total += v.Hits
+	}
+	return total
+}
+
+// DeviceRule 设备平台跳转规则
+type DeviceRule struct {
+	Platform  string `json:"platform"`
+	TargetURL string `json:"target_url"`
+}
+
+// LanguageRule 语言跳转规则，Language 为语言标签，如 zh、zh-CN、en
+type LanguageRule struct {
+	Language  string `json:"language"`
+	TargetURL string `json:"target_url"`
+}
+
+// SameBehavior 判断两条记录除目标地址外的跳转选项是否相同，不比较短代码、别名、备注、访问统计和隔离、健康检查状态
+func (r URLRecord) SameBehavior(other URLRecord) bool {
+	return r.PassQuery == other.PassQuery && r.QueryConflict == other.QueryConflict && r.PrefixMatch == other.PrefixMatch &&
+		r.PasswordHash == other.PasswordHash && r.MaxClicks == other.MaxClicks && r.ExhaustedURL == other.ExhaustedURL &&
+		r.Interstitial == other.Interstitial &&
+		slices.Equal(r.DeviceRules, other.DeviceRules) && slices.Equal(r.LanguageRules, other.LanguageRules) &&
+		slices.EqualFunc(r.Variants, other.Variants, func(a, b Variant) bool {
+			return a.Name == b.Name && a.TargetURL == b.TargetURL && a.Weight == b.Weight
+		}) && r.StickyVariant == other.StickyVariant &&
+		r.OGTitle == other.OGTitle && r.OGDescription == other.OGDescription && r.OGImage == other.OGImage
+}
+
+// IsExhausted 访问次数是否已用完
+func (r URLRecord) IsExhausted() bool {
+	return r.MaxClicks > 0 && r.ClickCount >= r.MaxClicks
+}
+
+// RemainingClicks 剩余访问次数，不限次数时返回-1
+func (r URLRecord) RemainingClicks() int {
+	if r.MaxClicks <= 0 {
+		return -1
+	}
+	if r.ClickCount >= r.MaxClicks {
+		return 0
+	}
+	return r.MaxClicks - r.ClickCount
+}
+
+// SetPassword 设置访问密码，密码为空时取消密码保护
+func (r *URLRecord) SetPassword(password string) error {
+	if password == "" {
+		r.PasswordHash = ""
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	r.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckPassword 校验访问密码
+func (r URLRecord) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(r.PasswordHash), []byte(password)) == nil
+}
+
+// HasPassword 是否设置了访问密码
+func (r URLRecord) HasPassword() bool {
+	return r.PasswordHash != ""
+}
+
+// IsValidQueryConflict 检查查询参数冲突策略是否合法，空值表示使用默认策略
+func IsValidQueryConflict(policy string) bool {
+	switch policy {
+	case "", QueryConflictKeepTarget, QueryConflictOverride, QueryConflictAppend:
+		return true
+	}
+	return false
+}
+
+// recordKey 记录在缓存中的键，不同域名下的短代码相互独立
+type recordKey struct {
+	domain string
+	code   string
+}
+
+// key 返回记录的缓存键
+func (r URLRecord) key() recordKey {
+	return recordKey{domain: r.Domain, code: r.ShortCode}
+}
+
+// NormalizeDomain 规范化域名：去掉端口和末尾的点并转为小写
+func NormalizeDomain(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -2,7 +2,6 @@ package storage
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -12,31 +11,33 @@ import (
 )
 
 const (
-	DataDir    = "data"
-	RecordFile = "shorten_records.json"
-	BackupDir  = "backups"
+	DataDir       = "data"
+	RecordFile    = "shorten_records.json"
+	TombstoneFile = "shorten_tombstones.json"
+	BackupDir     = "backups"
 )
 
-// URLRecord 表示一个短链接记录
-type URLRecord struct {
-	ShortCode  string    `json:"short_code"`
-	TargetURL  string    `json:"target_url"`
-	Remark     string    `json:"remark"`
-	CreateTime time.Time `json:"create_time"`
-}
-
 // URLStorage 处理短链接的存储
 type URLStorage struct {
-	mutex      sync.RWMutex
-	recordPath string
-	backupPath string
-	cache      map[string]*URLRecord
-	lastBackup time.Time
-	isDirty    bool
+	mutex         sync.RWMutex
+	recordPath    string
+	tombstonePath string
+	backupPath    string
+	cache         map[recordKey]*URLRecord
+	names         map[recordKey]recordKey          // 短代码和别名到记录主键的索引
+	targets       map[string]map[recordKey]bool    // 目标地址到记录主键的反向索引
+	lengths       map[lengthKey]map[recordKey]bool // 按域名和短代码长度分组的索引，用于推荐相近的短代码
+	tombstones    map[recordKey]time.Time          // 已删除的短链接及删除时间
+	lastBackup    time.Time
+	isDirty       bool
+	statsDirty    bool // 访问统计已变更但尚未写入文件
+
+	// 不区分大小写时，名称索引和删除记录的短代码均为小写
+	caseInsensitive bool
 }
 
-// NewURLStorage 创建一个新的URL存储实例
-func NewURLStorage() (*URLStorage, error) {
+// NewURLStorage 创建一个新的URL存储实例，caseInsensitive为true时短代码和别名不区分大小写
+func NewURLStorage(caseInsensitive bool) (*URLStorage, error) {
 	// 确保数据目录存在
 	if err := os.MkdirAll(DataDir, 0755); err != nil {
 		return nil, fmt.Errorf("创建数据目录失败: %w", err)
@@ -49,21 +50,34 @@ func NewURLStorage() (*URLStorage, error) {
 	}
 
 	storage := &URLStorage{
-		recordPath: filepath.Join(DataDir, RecordFile),
-		backupPath: backupPath,
-		cache:      make(map[string]*URLRecord),
-		lastBackup: time.Now(),
-		isDirty:    false,
+		recordPath:    filepath.Join(DataDir, RecordFile),
+		tombstonePath: filepath.Join(DataDir, TombstoneFile),
+		backupPath:    backupPath,
+		cache:         make(map[recordKey]*URLRecord),
+		tombstones:    make(map[recordKey]time.Time),
+		lastBackup:    time.Now(),
+		isDirty:       false,
+
+		caseInsensitive: caseInsensitive,
 	}
 
 	// 加载现有数据到缓存
 	if err := storage.loadFromFile(); err != nil {
 		return nil, fmt.Errorf("加载数据失败: %w", err)
 	}
+	if err := storage.buildIndex(); err != nil {
+		return nil, err
+	}
+	if err := storage.loadTombstones(); err != nil {
+		return nil, fmt.Errorf("加载已删除链接失败: %w", err)
+	}
 
 	// 启动定时备份
 	go storage.startBackupScheduler()
 
+	// 启动访问统计定时写入
+	go storage.startStatsFlusher()
+
 	return storage, nil
 }
 
@@ -84,10 +98,10 @@ func (s *URLStorage) loadFromFile() error {
 		return err
 	}
 
-	s.cache = make(map[string]*URLRecord)
+	s.cache = make(map[recordKey]*URLRecord)
 	for _, record := range records {
 		recordCopy := record
-		s.cache[record.ShortCode] = &recordCopy
+		s.cache[record.key()] = &recordCopy
 	}
 
 	return nil
@@ -108,7 +122,48 @@ func (s *URLStorage) saveToFile() error {
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(records)
+	if err := encoder.Encode(records); err != nil {
+		return err
+	}
+
+	s.statsDirty = false
+	return nil
+}
+
+// loadTombstones 从文件加载已删除的短链接
+func (s *URLStorage) loadTombstones() error {
+	data, err := os.ReadFile(s.tombstonePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var tombstones []Tombstone
+	if err := json.Unmarshal(data, &tombstones); err != nil {
+		return err
+	}
+
+	for _, t := range tombstones {
+		s.tombstones[s.fold(recordKey{domain: t.Domain, code: t.ShortCode})] = t.DeletedAt
+	}
+
+	return nil
+}
+
+// saveTombstones 将已删除的短链接保存到文件
+func (s *URLStorage) saveTombstones() error {
+	tombstones := make([]Tombstone, 0, len(s.tombstones))
+	for key, deletedAt := range s.tombstones {
+		tombstones = append(tombstones, Tombstone{Domain: key.domain, ShortCode: key.code, DeletedAt: deletedAt})
+	}
+
+	data, err := json.MarshalIndent(tombstones, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.tombstonePath, data, 0644)
 }
 
 // startBackupScheduler 启动定时备份任务
@@ -129,6 +184,24 @@ func (s *URLStorage) startBackupScheduler() {
 	}
 }
 
+// startStatsFlusher 定时将访问统计写入文件，避免每次跳转都重写数据文件
+func (s *URLStorage) startStatsFlusher() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mutex.Lock()
+		if s.statsDirty {
+			if err := s.saveToFile(); err != nil {
+				fmt.Printf("保存访问统计失败: %v\n", err)
+			} else {
+				s.statsDirty = false
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
 // createBackup 创建备份文件
 func (s *URLStorage) createBackup() error {
 	s.mutex.Lock()
@@ -176,17 +249,17 @@ func (s *URLStorage) GetAllURLs() ([]URLRecord, error) {
 	return result, nil
 }
 
-// GetURLByCode 通过短码获取URL记录
-func (s *URLStorage) GetURLByCode(code string) (*URLRecord, error) {
+// GetURL 通过域名和短码或别名获取URL记录，domain为空表示默认域名，返回的记录使用主短码
+func (s *URLStorage) GetURL(domain, code string) (*URLRecord, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	record, exists := s.cache[code]
+	key, exists := s.resolve(domain, code)
 	if !exists {
-		return nil, errors.New("链接不存在")
+		return nil, notFound(code)
 	}
 
-	recordCopy := *record
+	recordCopy := *s.cache[key]
 	return &recordCopy, nil
 }
 
@@ -195,47 +268,353 @@ func (s *URLStorage) CreateURL(record URLRecord) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if _, exists := s.cache[record.ShortCode]; exists {
-		return errors.New("短链接代码已存在")
+	record.Domain = NormalizeDomain(record.Domain)
+	if err := s.checkNames(record, record.key()); err != nil {
+		return err
+	}
+	if _, exists := s.cache[record.key()]; exists {
+		return &Error{Kind: ErrConflict, Field: FieldShortCode, Value: record.ShortCode}
 	}
 
 	record.CreateTime = time.Now()
+	for i := range record.Variants {
+		record.Variants[i].Hits = 0
+	}
 	recordCopy := record
-	s.cache[record.ShortCode] = &recordCopy
+	s.cache[record.key()] = &recordCopy
+	s.indexRecord(record)
 	s.isDirty = true
 
-	return s.saveToFile()
+	if err := s.saveToFile(); err != nil {
+		return err
+	}
+
+	// 重新使用已删除的短码或别名时移除删除记录
+	restored := false
+	for _, name := range record.names() {
+		if _, deleted := s.tombstones[s.fold(name)]; deleted {
+			delete(s.tombstones, s.fold(name))
+			restored = true
+		}
+	}
+	if restored {
+		return s.saveTombstones()
+	}
+	return nil
+}
+
+// CreateURLs 批量创建短链接，所有记录只写入一次文件
+// allOrNothing为true时任一记录失败则都不创建；返回与records一一对应的错误，创建成功的为nil
+func (s *URLStorage) CreateURLs(records []URLRecord, allOrNothing bool) []error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	errs := make([]error, len(records))
+	var created []URLRecord
+	now := time.Now()
+	for i, record := range records {
+		record.Domain = NormalizeDomain(record.Domain)
+		if err := s.checkNames(record, record.key()); err != nil {
+			errs[i] = err
+			continue
+		}
+		if _, exists := s.cache[record.key()]; exists {
+			errs[i] = &Error{Kind: ErrConflict, Field: FieldShortCode, Value: record.ShortCode}
+			continue
+		}
+
+		record.CreateTime = now
+		for j := range record.Variants {
+			record.Variants[j].Hits = 0
+		}
+		// 先加入缓存和索引，批量中后面的记录不能与其重名
+		recordCopy := record
+		s.cache[record.key()] = &recordCopy
+		s.indexRecord(record)
+		created = append(created, record)
+	}
+
+	// rollback 从缓存和索引中移除本次加入的记录
+	rollback := func() {
+		for _, record := range created {
+			s.unindexRecord(record)
+			delete(s.cache, record.key())
+		}
+	}
+
+	if allOrNothing && len(created) < len(records) {
+		rollback()
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = ErrBatchAborted
+			}
+		}
+		return errs
+	}
+	if len(created) == 0 {
+		return errs
+	}
+
+	s.isDirty = true
+	if err := s.saveToFile(); err != nil {
+		// 保存失败时撤销创建，避免内存中存在未写入文件的记录
+		rollback()
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+		return errs
+	}
+
+	// 重新使用已删除的短码或别名时移除删除记录
+	restored := false
+	for _, record := range created {
+		for _, name := range record.names() {
+			if _, deleted := s.tombstones[s.fold(name)]; deleted {
+				delete(s.tombstones, s.fold(name))
+				restored = true
+			}
+		}
+	}
+	if restored {
+		if err := s.saveTombstones(); err != nil {
+			fmt.Printf("保存已删除链接失败: %v\n", err)
+		}
+	}
+	return errs
 }
 
-// UpdateURL 更新现有的短链接
+// UpdateURL 更新现有的短链接，按域名和短码定位记录
 func (s *URLStorage) UpdateURL(record URLRecord) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	existing, exists := s.cache[record.ShortCode]
+	existing, exists := s.cache[record.key()]
 	if !exists {
-		return errors.New("链接不存在")
+		return notFound(record.ShortCode)
+	}
+	if err := s.checkNames(record, record.key()); err != nil {
+		return err
 	}
 
 	record.CreateTime = existing.CreateTime
+	record.ClickCount = existing.ClickCount
+	if record.TargetURL == existing.TargetURL {
+		record.Health = existing.Health
+	}
+
+	// 保留同名版本的访问统计
+	for i := range record.Variants {
+		record.Variants[i].Hits = 0
+		for _, v := range existing.Variants {
+			if v.Name == record.Variants[i].Name {
+				record.Variants[i].Hits = v.Hits
+				break
+			}
+		}
+	}
+	s.unindexRecord(*existing)
 	recordCopy := record
-	s.cache[record.ShortCode] = &recordCopy
+	s.cache[record.key()] = &recordCopy
+	s.indexRecord(record)
 	s.isDirty = true
 
 	return s.saveToFile()
 }
 
 // DeleteURL 删除短链接
-func (s *URLStorage) DeleteURL(shortCode string) error {
+func (s *URLStorage) DeleteURL(domain, shortCode string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, exists := s.resolve(domain, shortCode)
+	if !exists {
+		return notFound(shortCode)
+	}
+
+	// 短代码和别名都记为已删除
+	record := s.cache[key]
+	s.unindexRecord(*record)
+	delete(s.cache, key)
+	for _, name := range record.names() {
+		s.tombstones[s.fold(name)] = time.Now()
+	}
+	s.isDirty = true
+
+	if err := s.saveToFile(); err != nil {
+		return err
+	}
+	return s.saveTombstones()
+}
+
+// RenameURL 修改短链接的短代码，保留创建时间、访问统计等所有字段
+// keepAlias为true时旧代码作为别名继续跳转，否则旧代码记为已删除，访问时返回410
+// 整个过程在同一把写锁内完成，并发访问不会看到新旧代码都不存在的中间状态
+func (s *URLStorage) RenameURL(domain, shortCode, newCode string, keepAlias bool) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if _, exists := s.cache[shortCode]; !exists {
-		return errors.New("链接不存在")
+	key, record, err := s.renamed(domain, shortCode, newCode, keepAlias)
+	if err != nil {
+		return err
 	}
 
-	delete(s.cache, shortCode)
+	existing := s.cache[key]
+	s.unindexRecord(*existing)
+	delete(s.cache, key)
+	s.cache[record.key()] = &record
+	s.indexRecord(record)
+	// 只改变大小写时旧代码仍指向该链接，不记为已删除
+	if !keepAlias && s.fold(key) != s.fold(record.key()) {
+		s.tombstones[s.fold(key)] = time.Now()
+	}
+	delete(s.tombstones, s.fold(record.key()))
+	s.isDirty = true
+
+	if err := s.saveToFile(); err != nil {
+		return err
+	}
+	return s.saveTombstones()
+}
+
+// RenamedRecord 返回修改短代码后将要保存的记录，不修改存储，用于保存前检查跳转链
+func (s *URLStorage) RenamedRecord(domain, shortCode, newCode string, keepAlias bool) (URLRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, record, err := s.renamed(domain, shortCode, newCode, keepAlias)
+	return record, err
+}
+
+// renamed 生成修改短代码后的记录并检查名称冲突，调用者需持有锁
+func (s *URLStorage) renamed(domain, shortCode, newCode string, keepAlias bool) (recordKey, URLRecord, error) {
+	key, exists := s.resolve(domain, shortCode)
+	if !exists {
+		return key, URLRecord{}, notFound(shortCode)
+	}
+	existing := s.cache[key]
+	if existing.ShortCode == newCode {
+		return key, URLRecord{}, &Error{Kind: ErrInvalid, Field: FieldShortCode, Value: newCode}
+	}
+
+	record := *existing
+	record.ShortCode = newCode
+
+	// 新代码原本是该链接的别名时不再作为别名保留
+	record.Aliases = nil
+	for _, alias := range existing.Aliases {
+		if !s.HasName(URLRecord{Domain: domain, ShortCode: alias}, newCode) {
+			record.Aliases = append(record.Aliases, alias)
+		}
+	}
+	// 不区分大小写时只改变大小写的旧代码与新代码是同一个名称，无需保留为别名
+	if keepAlias && s.fold(key) != s.fold(record.key()) {
+		record.Aliases = append(record.Aliases, existing.ShortCode)
+	}
+
+	if err := s.checkNames(record, key); err != nil {
+		return key, URLRecord{}, err
+	}
+	return key, record, nil
+}
+
+// IsDeleted 判断短链接是否曾经存在并已被删除
+func (s *URLStorage) IsDeleted(domain, shortCode string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, deleted := s.tombstones[s.fold(recordKey{domain: domain, code: shortCode})]
+	return deleted
+}
+
+// ConsumeClick 为限制次数的链接消耗一次访问，次数已用完时返回ErrClicksExhausted
+// 检查与计数在同一把写锁内完成，并发请求不会超出限制
+func (s *URLStorage) ConsumeClick(domain, shortCode string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, exists := s.cache[recordKey{domain: domain, code: shortCode}]
+	if !exists {
+		return notFound(shortCode)
+	}
+
+	if record.MaxClicks <= 0 {
+		return nil
+	}
+	if record.IsExhausted() {
+		return ErrClicksExhausted
+	}
+
+	record.ClickCount++
+	s.isDirty = true
+
+	// 立即持久化，避免重启后一次性链接被重复使用
+	return s.saveToFile()
+}
+
+// RecordVariantHit 记录一次A/B版本访问，统计数据定时写入文件
+func (s *URLStorage) RecordVariantHit(domain, shortCode, variant string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, exists := s.cache[recordKey{domain: domain, code: shortCode}]
+	if !exists {
+		return notFound(shortCode)
+	}
+
+	// 复制切片，避免影响已返回给调用方的记录副本
+	variants := append([]Variant(nil), record.Variants...)
+	for i := range variants {
+		if variants[i].Name == variant {
+			variants[i].Hits++
+			record.Variants = variants
+			s.statsDirty = true
+			return nil
+		}
+	}
+
+	return &Error{Kind: ErrNotFound, Field: FieldVariant, Value: variant}
+}
+
+// SetQuarantine 隔离短链接或解除隔离，reason为空表示解除
+func (s *URLStorage) SetQuarantine(domain, shortCode, reason string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, exists := s.cache[recordKey{domain: domain, code: shortCode}]
+	if !exists {
+		return notFound(shortCode)
+	}
+	if record.QuarantineReason == reason {
+		return nil
+	}
+
+	record.QuarantineReason = reason
+	record.QuarantinedAt = nil
+	if reason != "" {
+		now := time.Now()
+		record.QuarantinedAt = &now
+	}
 	s.isDirty = true
 
 	return s.saveToFile()
 }
+
+// SetHealth 保存目标地址的健康检查结果，检查期间目标地址已被修改时忽略，结果定时写入文件
+func (s *URLStorage) SetHealth(domain, shortCode, targetURL string, health HealthStatus) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, exists := s.cache[recordKey{domain: domain, code: shortCode}]
+	if !exists {
+		return notFound(shortCode)
+	}
+	if record.TargetURL != targetURL {
+		return nil
+	}
+
+	record.Health = &health
+	s.statsDirty = true
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package urlcheck 校验并规范化短链接的目标地址
+package urlcheck
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors 多个字段的校验错误
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validator 目标地址校验器
+type Validator struct {
+	schemes      []string
+	maxLength    int
+	denyHosts    []string
+	denyPatterns []*regexp.Regexp
+}
+
+// New 创建校验器，maxLength为0表示不限制长度
+func New(schemes []string, maxLength int, denyHosts []string, denyPatterns []*regexp.Regexp) *Validator {
+	lower := make([]string, len(schemes))
+	for i, s := range schemes {
+		lower[i] = strings.ToLower(s)
+	}
+	return &Validator{
+		schemes:      lower,
+		maxLength:    maxLength,
+		denyHosts:    denyHosts,
+		denyPatterns: denyPatterns,
+	}
+}
+
+// Normalize 校验地址并返回规范化后的结果：协议和域名转为小写，国际化域名转为punycode，去掉默认端口
+func (v *Validator) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("地址不能为空")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", errors.New("地址格式错误")
+	}
+	if u.Scheme == "" {
+		return "", errors.New("必须是包含协议的完整地址，如 https://example.com")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !slices.Contains(v.schemes, u.Scheme) {
+		return "", fmt.Errorf("不允许的协议: %s，可选值: %s", u.Scheme, strings.Join(v.schemes, ", "))
+	}
+
+	if u.Scheme == "http" || u.Scheme == "https" {
+		if err := normalizeHost(u); err != nil {
+			return "", err
+		}
+	}
+
+	normalized := u.String()
+	if v.maxLength > 0 && len(normalized) > v.maxLength {
+		return "", fmt.Errorf("地址长度不能超过%d个字符", v.maxLength)
+	}
+
+	if v.isDenied(u.Hostname(), normalized) {
+		return "", errors.New("该地址已被禁止使用")
+	}
+
+	return normalized, nil
+}
+
+// normalizeHost 规范化HTTP地址的主机部分
+func normalizeHost(u *url.URL) error {
+	hostname := u.Hostname()
+	if hostname == "" {
+		return errors.New("地址缺少域名")
+	}
+
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	// IP地址不做IDN转换
+	if net.ParseIP(hostname) == nil {
+		ascii, err := idna.Lookup.ToASCII(hostname)
+		if err != nil {
+			return fmt.Errorf("域名格式错误: %s", hostname)
+		}
+		hostname = ascii
+	}
+
+	host := hostname
+	if strings.Contains(hostname, ":") {
+		host = "[" + hostname + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	return nil
+}
+
+// isDenied 判断地址是否命中禁止列表，域名规则同时匹配其子域名
+func (v *Validator) isDenied(hostname, normalized string) bool {
+	hostname = strings.TrimSuffix(hostname, ".")
+	for _, deny := range v.denyHosts {
+		if hostname == deny || strings.HasSuffix(hostname, "."+deny) {
+			return true
+		}
+	}
+	for _, re := range v.denyPatterns {
+		if re.MatchString(normalized) {
+			return true
+		}
+	}
+	return false
+}
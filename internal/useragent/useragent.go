@@ -0,0 +1,82 @@
+// Package useragent 根据 User-Agent 识别访问设备的平台以及社交平台爬虫
+package useragent
+
+import (
+	"slices"
+	"strings"
+)
+
+// 支持的平台
+const (
+	IOS     = "ios"
+	Android = "android"
+	Windows = "windows"
+	MacOS   = "macos"
+	Linux   = "linux"
+	Mobile  = "mobile"  // 手机和平板，含 iOS 和 Android
+	Desktop = "desktop" // 非移动设备
+)
+
+// AllPlatforms 全部平台，用于校验和表单展示
+var AllPlatforms = []string{IOS, Android, Windows, MacOS, Linux, Mobile, Desktop}
+
+// IsValidPlatform 检查平台名称是否受支持
+func IsValidPlatform(platform string) bool {
+	return slices.Contains(AllPlatforms, platform)
+}
+
+// Platforms 返回 User-Agent 所属的全部平台，例如 iPhone 同时属于 ios 和 mobile
+// 注意 iPadOS 13 起默认使用桌面版 Safari 的 User-Agent，会被识别为 macos
+func Platforms(ua string) []string {
+	ua = strings.ToLower(ua)
+
+	var result []string
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ipod"):
+		result = append(result, IOS)
+	case strings.Contains(ua, "android"):
+		result = append(result, Android)
+	case strings.Contains(ua, "windows"):
+		result = append(result, Windows)
+	case strings.Contains(ua, "macintosh") || strings.Contains(ua, "mac os x"):
+		result = append(result, MacOS)
+	case strings.Contains(ua, "linux") || strings.Contains(ua, "x11"):
+		result = append(result, Linux)
+	}
+
+	if isMobile(ua) {
+		result = append(result, Mobile)
+	} else {
+		result = append(result, Desktop)
+	}
+	return result
+}
+
+func isMobile(ua string) bool {
+	for _, keyword := range []string{"iphone", "ipad", "ipod", "android", "mobile", "windows phone", "harmonyos"} {
+		if strings.Contains(ua, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// 常见聊天和社交平台抓取链接预览时使用的爬虫标识（小写）
+var crawlerKeywords = []string{
+	"facebookexternalhit", "facebot", "twitterbot", "linkedinbot",
+	"slackbot", "slack-imgproxy", "discordbot", "telegrambot",
+	"whatsapp", "skypeuripreview", "redditbot", "pinterest",
+	"embedly", "iframely", "vkshare", "mastodon", "bluesky",
+	"applebot", "snapchat", "line-poker", "kakaotalk-scrap",
+}
+
+// IsCrawler 检查 User-Agent 是否为抓取链接预览的社交平台爬虫
+func IsCrawler(ua string) bool {
+	ua = strings.ToLower(ua)
+	for _, keyword := range crawlerKeywords {
+		if strings.Contains(ua, keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,8 @@
+package templates
+
+import _ "embed"
+
+// OpenAPISpec API接口的OpenAPI 3描述文档
+//
+//go:embed openapi.json
+var OpenAPISpec []byte